@@ -1,26 +1,294 @@
 package cache
 
-import "time"
+import (
+	"log/slog"
+	"time"
 
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTtl is ttl of written values unless WithTtl is used.
+const DefaultTtl = 5 * time.Minute
+
+// NewCacheBuilder creates builder of cache serving entities of modelVersion only,
+// Unversioned model version disables the check, WithVersionedKeys is not applicable then.
 func NewCacheBuilder[T Entity, V any](
 	modelVersion uint16,
 	providers ...Provider[T, V],
 ) *Builder[T, V] {
 	return &Builder[T, V]{
 		providers:    providers,
-		ttl:          5 * time.Minute,
+		ttl:          DefaultTtl,
 		modelVersion: modelVersion,
+		observer:     noopObserver{},
+		logger:       NewSlogLogger(nil),
+		readRepair:   true,
 	}
 }
 
+// NewTieredCache wires fast l1 (usually in-memory) in front of slower l2 (usually remote).
+// Reads go l1 then l2, values found in l2 or source are written back to every tier that missed them,
+// so l2 hit populates l1. Returned builder is configured with the usual With* methods.
+func NewTieredCache[T Entity, V any](
+	modelVersion uint16,
+	l1 Provider[T, V],
+	l2 Provider[T, V],
+) *Builder[T, V] {
+	return NewCacheBuilder[T, V](modelVersion, l1, l2)
+}
+
+// Build creates cache without validation, cache without providers queries source on every read.
+// Builder is copied, so changing it after Build does not affect built cache,
+// use Cache.SetModelVersion, Cache.SetTtl and Cache.SetNegativeTtl instead.
 func (b *Builder[T, V]) Build() *Cache[T, V] {
-	return &Cache[T, V]{
-		builder: b,
+	cfg := *b
+	cfg.providers = append([]Provider[T, V](nil), b.providers...)
+	cfg.acceptedVersions = append([]uint16(nil), b.acceptedVersions...)
+
+	c := &Cache[T, V]{
+		builder:   &cfg,
+		promotion: newPromotionSketch(b.promotionThreshold),
 	}
+	c.modelVersion.Store(uint32(b.modelVersion))
+	c.ttl.Store(int64(b.ttl))
+	c.negativeTtl.Store(int64(b.negativeTtl))
+
+	if b.invalidTtl < 0 {
+		b.logger.Warn(errors.Errorf("negative ttl %v", b.invalidTtl), "ttl is replaced by default one",
+			slog.Duration("ttl", DefaultTtl))
+	}
+
+	if b.writeBehindInterval > 0 {
+		c.writeBehind = newWriteBehind[T, V](b.writeBehindBatch)
+		go c.writeBehind.run(b.writeBehindInterval, c.flushWriteBehind)
+	}
+
+	return c
 }
 
+// MustBuild is Build which panics with ErrNoProviders when no providers are configured,
+// so misconfiguration is caught on startup instead of showing up as zero hit rate.
+func (b *Builder[T, V]) MustBuild() *Cache[T, V] {
+	if len(b.providers) == 0 {
+		panic(ErrNoProviders)
+	}
+
+	return b.Build()
+}
+
+// WithTtl sets ttl of written values, 5 minutes by default. Zero ttl means provider default:
+// redis and memcached store values without expiration, LRUCache uses ttl passed to NewLRUCache
+// and MapCache ignores ttl anyway. Negative ttl is replaced by default one with warning logged by Build.
 func (b *Builder[T, V]) WithTtl(ttl time.Duration) *Builder[T, V] {
+	b.invalidTtl = 0
+
+	if ttl < 0 {
+		b.invalidTtl = ttl
+		ttl = DefaultTtl
+	}
+
 	b.ttl = ttl
 
 	return b
 }
+
+// WithSingleflight collapses concurrent source calls of Cache.Get for the same key into one.
+// Shared call is not canceled with context of caller which started it, use WithSourceTimeout to bound it.
+func (b *Builder[T, V]) WithSingleflight(enabled bool) *Builder[T, V] {
+	b.singleflight = enabled
+
+	return b
+}
+
+// WithNegativeCaching stores tombstone with given ttl when source returns nil value in Cache.Get
+// or does not return requested key in Cache.MGet.
+func (b *Builder[T, V]) WithNegativeCaching(ttl time.Duration) *Builder[T, V] {
+	b.negativeTtl = ttl
+
+	return b
+}
+
+func (b *Builder[T, V]) WithObserver(observer Observer) *Builder[T, V] {
+	if observer == nil {
+		observer = noopObserver{}
+	}
+
+	b.observer = observer
+
+	return b
+}
+
+// WithTracer enables spans for Get / MGet, provider calls and source calls.
+func (b *Builder[T, V]) WithTracer(tracer trace.Tracer) *Builder[T, V] {
+	b.tracer = tracer
+
+	return b
+}
+
+// WithSyncWriteback makes Cache.MGet write values fetched from source to providers before returning.
+// By default writeback is async, so MGet latency does not include provider writes,
+// but a read right after MGet may still miss the values which were just fetched.
+func (b *Builder[T, V]) WithSyncWriteback(enabled bool) *Builder[T, V] {
+	b.syncWriteback = enabled
+
+	return b
+}
+
+// WithWritebackErrorHandler is called from writeback of Cache.MGet instead of logging when provider fails to store values.
+func (b *Builder[T, V]) WithWritebackErrorHandler(handler func(err error)) *Builder[T, V] {
+	b.writebackErrorHandler = handler
+
+	return b
+}
+
+// WithLogger sets logger for errors which are not returned to caller, slog.Default is used by default.
+// Zerolog adapter is available in zerolog sub-module.
+func (b *Builder[T, V]) WithLogger(logger Logger) *Builder[T, V] {
+	if logger == nil {
+		logger = NewSlogLogger(nil)
+	}
+
+	b.logger = logger
+
+	return b
+}
+
+// WithSlog routes logging of Get and MGet into logger.
+func (b *Builder[T, V]) WithSlog(logger *slog.Logger) *Builder[T, V] {
+	return b.WithLogger(NewSlogLogger(logger))
+}
+
+// WithDefaultLoader registers source functions used by Get, MGet, Refresh and MRefresh when nil fn is passed.
+// Explicitly passed fn and loaders attached to context by WithLoader still take precedence.
+func (b *Builder[T, V]) WithDefaultLoader(
+	single GetSingleFromSourceFn[T, V],
+	multi GetFromSourceFn[T, V],
+) *Builder[T, V] {
+	b.singleLoader = single
+	b.multiLoader = multi
+
+	return b
+}
+
+// WithBatchCoalescing shares source loads between concurrent MGet calls: keys which are already loaded
+// by another MGet are not passed to fn, their results are awaited instead. Failure of that load fails
+// waiting MGet as well, and source functions of concurrent calls are assumed to be interchangeable.
+// Shared load is not canceled with context of caller which started it, use WithSourceTimeout to bound it.
+func (b *Builder[T, V]) WithBatchCoalescing(enabled bool) *Builder[T, V] {
+	b.batchCoalescing = enabled
+
+	return b
+}
+
+// WithSourceTimeout bounds every source call with timeout, provider calls still use caller context.
+func (b *Builder[T, V]) WithSourceTimeout(timeout time.Duration) *Builder[T, V] {
+	b.sourceTimeout = timeout
+
+	return b
+}
+
+// WithStrictVersionOnSet makes Set and MSet reject entities with model version different from builder one.
+// Such entities are written by default and are treated as missing on every read.
+func (b *Builder[T, V]) WithStrictVersionOnSet(strict bool) *Builder[T, V] {
+	b.strictVersion = strict
+
+	return b
+}
+
+// WithPartialResults makes MGet return values found in providers together with source error,
+// instead of failing whole batch. Keys which were not loaded are absent from result.
+func (b *Builder[T, V]) WithPartialResults(partial bool) *Builder[T, V] {
+	b.partialResults = partial
+
+	return b
+}
+
+// WithReadRepair controls whether value found in slower provider is written back to faster providers
+// which missed it, enabled by default. Values loaded from source are written back regardless.
+func (b *Builder[T, V]) WithReadRepair(enabled bool) *Builder[T, V] {
+	b.readRepair = enabled
+
+	return b
+}
+
+// WithParallelReads makes Cache.Get read all providers concurrently and return first hit instead of reading
+// them in order, reads still in flight are cancelled. Useful with several remote tiers.
+// Hit of any tier wins, so faster tier may serve value older than slower one holds.
+func (b *Builder[T, V]) WithParallelReads(enabled bool) *Builder[T, V] {
+	b.parallelReads = enabled
+
+	return b
+}
+
+// WithKeyFunc sets derivation of cache key from original value used by Cache.NewKey,
+// so every call site formats keys the same way. fmt.Sprint is used by default.
+func (b *Builder[T, V]) WithKeyFunc(fn KeyFunc[V]) *Builder[T, V] {
+	b.keyFunc = fn
+
+	return b
+}
+
+// WithWriteBehind buffers values written back by MGet and flushes them to providers every interval
+// or once maxBatch values are buffered, so many small writes are coalesced into few large ones.
+// Values become visible in providers with delay, negatively cached keys are still written immediately.
+// Cache.Close must be called to flush buffered values and stop background flushing.
+func (b *Builder[T, V]) WithWriteBehind(interval time.Duration, maxBatch int) *Builder[T, V] {
+	b.writeBehindInterval = interval
+	b.writeBehindBatch = maxBatch
+
+	return b
+}
+
+// WithAcceptedVersions makes Get and MGet accept entries of listed older model versions, checked in given order,
+// instead of loading them from source after model version bump. Providers must be built with
+// WithKeepStaleVersions(true), otherwise older entries are deleted by first read of current version.
+// Such entries are returned as is unless WithVersionUpgrader is set.
+func (b *Builder[T, V]) WithAcceptedVersions(versions ...uint16) *Builder[T, V] {
+	b.acceptedVersions = versions
+
+	return b
+}
+
+// WithVersionUpgrader converts entries of accepted older versions to current model version before they are returned,
+// upgraded values are written back to providers. Entries which fail to upgrade are loaded from source.
+func (b *Builder[T, V]) WithVersionUpgrader(fn VersionUpgrader[T]) *Builder[T, V] {
+	b.upgrader = fn
+
+	return b
+}
+
+// WithFailOnProviderError makes Get return provider error instead of loading key from source when no other provider
+// has it, so degraded cache does not overload source. Disabled by default, MGet is not affected.
+func (b *Builder[T, V]) WithFailOnProviderError(enabled bool) *Builder[T, V] {
+	b.failOnProviderError = enabled
+
+	return b
+}
+
+// WithPromotionThreshold makes read repair write value found in slower provider to faster ones only after
+// it was served by slower provider n times, so one-hit keys do not evict hot ones from small in-memory tier.
+// Hits are counted approximately and decay over time. Values loaded from source are always written back.
+func (b *Builder[T, V]) WithPromotionThreshold(n int) *Builder[T, V] {
+	b.promotionThreshold = n
+
+	return b
+}
+
+// WithWriteThrough makes Cache.Set and Cache.MSet persist records with writer, so cache and source do not diverge.
+// By default writer is called first and providers are not written when it fails, keys which providers fail
+// to write afterwards are deleted from providers. Values loaded from source, e.g. by Refresh or Warm,
+// are not written to source.
+func (b *Builder[T, V]) WithWriteThrough(writer SourceWriteFn[T]) *Builder[T, V] {
+	b.writeThrough = writer
+
+	return b
+}
+
+// WithWriteThroughCacheFirst makes write-through MSet write providers before writer, writer is not called
+// when providers fail. Records are deleted from providers when either of them fails.
+func (b *Builder[T, V]) WithWriteThroughCacheFirst(enabled bool) *Builder[T, V] {
+	b.writeThroughCacheFirst = enabled
+
+	return b
+}
@@ -2,81 +2,620 @@ package cache
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
-	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (c *Cache[T, V]) Get(ctx context.Context, key *Key[V], fn GetSingleFromSourceFn[T, V]) (*T, error) {
+// Get reads providers in order, or concurrently with WithParallelReads, and returns first value found.
+// Tiers holding different values are not reconciled.
+// On miss value is loaded by fn and written to providers which missed it, see WithReadRepair for provider hits.
+func (c *Cache[T, V]) Get(ctx context.Context, key *Key[V], fn GetSingleFromSourceFn[T, V], opts ...CallOption) (*T, error) {
+	v, _, err := c.GetWithMeta(ctx, key, fn, opts...)
+
+	return v, err
+}
+
+// GetWithMeta is Get which also reports whether value was served by cache provider or source.
+func (c *Cache[T, V]) GetWithMeta(
+	ctx context.Context,
+	key *Key[V],
+	fn GetSingleFromSourceFn[T, V],
+	opts ...CallOption,
+) (*T, GetResult, error) {
+	if fn == nil {
+		fn = c.singleLoader(ctx)
+	}
+
+	return c.get(ctx, key, withoutTtl(fn), opts)
+}
+
+// GetWithTTLFromSource is Get for source which reports ttl of loaded value, e.g. from Cache-Control max-age.
+// Value is written back with that ttl, zero ttl means ttl configured by builder or WithCallTtl.
+func (c *Cache[T, V]) GetWithTTLFromSource(
+	ctx context.Context,
+	key *Key[V],
+	fn GetSingleFromSourceFnWithTTL[T, V],
+	opts ...CallOption,
+) (*T, error) {
+	if fn == nil {
+		fn = withoutTtl(c.singleLoader(ctx))
+	}
+
+	v, _, err := c.get(ctx, key, fn, opts)
+
+	return v, err
+}
+
+func withoutTtl[T, V any](fn GetSingleFromSourceFn[T, V]) GetSingleFromSourceFnWithTTL[T, V] {
+	if fn == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, key *Key[V]) (*T, time.Duration, error) {
+		v, err := fn(ctx, key)
+
+		return v, 0, err
+	}
+}
+
+func (c *Cache[T, V]) get(
+	ctx context.Context,
+	key *Key[V],
+	fn GetSingleFromSourceFnWithTTL[T, V],
+	opts []CallOption,
+) (*T, GetResult, error) {
+	if c.closed.Load() {
+		return nil, GetResult{ProviderIndex: -1}, errors.WithStack(ErrClosed)
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.Get")
+	defer span.End()
+
+	o := c.callOptions(opts)
+	modelVersion := c.ModelVersion()
+
 	var missingIn []Provider[T, V]
 	var finalValue *T
+	var tombstoned bool
+	var providerErr error
+	result := GetResult{ProviderIndex: -1}
+
+	if c.builder.parallelReads {
+		finalValue, tombstoned, result.ProviderIndex, missingIn, providerErr = c.getParallel(ctx, key, modelVersion, o)
+	} else {
+		finalValue, tombstoned, result.ProviderIndex, missingIn, providerErr = c.getSequential(ctx, key, modelVersion, o)
+	}
 
-	for _, provider := range c.builder.providers {
-		v, err := provider.Get(ctx, key, c.builder.modelVersion)
+	accepted := false
 
-		if err != nil {
-			zerolog.Ctx(ctx).Err(err).Send() // todo looks like cache is invalid
-			continue
-		}
+	if finalValue == nil && !tombstoned && len(c.builder.acceptedVersions) > 0 {
+		values, indexes := c.getAccepted(ctx, []*Key[V]{key}, modelVersion, o)
 
-		if v != nil {
-			finalValue = v
-			break
+		if v, ok := values[key]; ok {
+			finalValue, result.ProviderIndex, accepted = v, indexes[key], true
 		}
+	}
 
-		missingIn = append(missingIn, provider)
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.Int("cache.key_count", 1),
+			attribute.Bool("cache.hit", finalValue != nil || tombstoned),
+			attribute.Bool("cache.source_invoked", finalValue == nil && !tombstoned && fn != nil),
+		)
 	}
 
-	if finalValue == nil {
+	if finalValue != nil || tombstoned {
+		result.Hit = true
+		c.recordHits(OperationGet, 1)
+	} else {
+		c.recordMisses(OperationGet, 1)
+
+		if providerErr != nil && c.builder.failOnProviderError {
+			return nil, result, providerErr
+		}
+
 		if fn == nil {
-			return nil, errors.New("get single from source is not defined")
+			return nil, result, errors.WithStack(ErrNoSourceFn)
 		}
 
+		result.FromSource = true
+
+		start := time.Now()
+		sourceCtx, sourceSpan, cancel := c.startSource(ctx)
+
 		var err error
-		finalValue, err = fn(ctx, key)
+		var sourceTtl time.Duration
+
+		finalValue, sourceTtl, err = c.getSingleFromSource(sourceCtx, key, fn)
+		cancel()
+
+		if sourceTtl > 0 {
+			o.ttl = sourceTtl
+		}
+
+		if errors.Is(err, ErrDoNotCache) {
+			missingIn, err = nil, nil
+		}
+
+		endSpan(sourceSpan, err)
+		c.recordSourceCall(OperationGet, start, err)
 
 		if err != nil { // can not get from source
-			return nil, errors.Wrap(err, "can not get from source")
+			return nil, result, errors.WithStack(&SourceError{Err: err})
 		}
 	}
 
-	if len(missingIn) > 0 {
+	repair := result.FromSource
+	switch {
+	case accepted:
+		repair = c.builder.upgrader != nil // entries of older version are not written back as is
+	case !repair && c.builder.readRepair && finalValue != nil && len(missingIn) > 0:
+		repair = c.promotion.hit(key.Key)
+	}
+
+	if len(missingIn) > 0 && finalValue != nil && repair {
 		setMap := map[string]*T{
 			key.Key: finalValue,
 		}
 		for _, m := range missingIn {
-			if err := m.MSet(ctx, setMap, c.builder.ttl); err != nil { // todo
-				zerolog.Ctx(ctx).Err(err).Send()
+			if circuitOpen(m) {
+				continue
+			}
+
+			if err := c.msetProvider(ctx, m, setMap, o.ttl); err != nil && !errors.Is(err, ErrCircuitOpen) {
+				c.recordSetFailure(OperationGet)
+				c.builder.logger.Error(err, "can not backfill provider", keyCount(1))
+			}
+		}
+	}
+
+	if negativeTtl := c.NegativeTtl(); len(missingIn) > 0 && finalValue == nil && negativeTtl > 0 {
+		for _, m := range missingIn {
+			if circuitOpen(m) {
+				continue
+			}
+
+			if err := m.SetTombstones(ctx, []string{key.Key}, modelVersion, negativeTtl); err != nil &&
+				!errors.Is(err, ErrCircuitOpen) {
+				c.recordSetFailure(OperationGet)
+				c.builder.logger.Error(err, "can not set tombstone", keyCount(1))
+			}
+		}
+	}
+
+	return finalValue, result, nil
+}
+
+// getSequential reads providers in order until first hit, returns value, whether key is tombstoned,
+// index of provider which served it, providers which missed it and first provider error.
+func (c *Cache[T, V]) getSequential(
+	ctx context.Context,
+	key *Key[V],
+	modelVersion uint16,
+	o *callOptions,
+) (*T, bool, int, []Provider[T, V], error) {
+	var missingIn []Provider[T, V]
+	var providerErr error
+
+	for i, provider := range c.builder.providers {
+		if o.skips(i) {
+			missingIn = append(missingIn, provider)
+			continue
+		}
+
+		v, err := c.getFromProvider(ctx, provider, key, modelVersion)
+
+		if errors.Is(err, ErrTombstone) {
+			return nil, true, i, missingIn, providerErr
+		}
+
+		if err != nil {
+			c.builder.logger.Error(err, "can not get from provider", // todo looks like cache is invalid
+				slog.String("provider", providerName(provider)), keyCount(1))
+
+			if providerErr == nil {
+				providerErr = errors.Wrapf(err, "can not get from provider %v", providerName(provider))
+			}
+
+			missingIn = append(missingIn, provider) // backfill once provider recovers
+			continue
+		}
+
+		if v != nil {
+			return v, false, i, missingIn, providerErr
+		}
+
+		missingIn = append(missingIn, provider)
+	}
+
+	return nil, false, -1, missingIn, providerErr
+}
+
+// getParallel is getSequential which reads all providers at once and cancels remaining reads on first hit.
+// Only providers which completed with miss or error are reported as missing, cancelled ones are not.
+func (c *Cache[T, V]) getParallel(
+	ctx context.Context,
+	key *Key[V],
+	modelVersion uint16,
+	o *callOptions,
+) (*T, bool, int, []Provider[T, V], error) {
+	type providerResult struct {
+		index int
+		value *T
+		err   error
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	providers := c.builder.providers
+	results := make(chan providerResult, len(providers))
+	missed := make([]bool, len(providers))
+	started := 0
+
+	for i, provider := range providers {
+		if o.skips(i) {
+			missed[i] = true
+			continue
+		}
+
+		started++
+
+		go func(i int, provider Provider[T, V]) {
+			v, err := c.getFromProvider(raceCtx, provider, key, modelVersion)
+			results <- providerResult{index: i, value: v, err: err}
+		}(i, provider)
+	}
+
+	var value *T
+	var providerErr error
+	tombstoned := false
+	hitIndex := -1
+
+	for ; started > 0; started-- {
+		r := <-results
+
+		switch {
+		case errors.Is(r.err, ErrTombstone):
+			if hitIndex < 0 {
+				hitIndex, tombstoned = r.index, true
+				cancel()
+			}
+		case r.err != nil:
+			if hitIndex < 0 || !errors.Is(r.err, context.Canceled) {
+				c.builder.logger.Error(r.err, "can not get from provider",
+					slog.String("provider", providerName(providers[r.index])), keyCount(1))
+
+				if providerErr == nil {
+					providerErr = errors.Wrapf(r.err, "can not get from provider %v", providerName(providers[r.index]))
+				}
+
+				missed[r.index] = true // backfill once provider recovers
+			}
+		case r.value != nil:
+			if hitIndex < 0 {
+				hitIndex, value = r.index, r.value
+				cancel()
+			}
+		default:
+			missed[r.index] = true
+		}
+	}
+
+	var missingIn []Provider[T, V]
+
+	for i, provider := range providers {
+		if missed[i] {
+			missingIn = append(missingIn, provider)
+		}
+	}
+
+	return value, tombstoned, hitIndex, missingIn, providerErr
+}
+
+func (c *Cache[T, V]) getFromProvider(ctx context.Context, provider Provider[T, V], key *Key[V], modelVersion uint16) (*T, error) {
+	providerCtx, providerSpan := c.startSpan(ctx, "cache.provider.Get")
+	v, err := provider.Get(providerCtx, key, modelVersion)
+
+	if providerSpan.IsRecording() {
+		providerSpan.SetAttributes(
+			attribute.String("cache.provider", providerName(provider)),
+			attribute.Bool("cache.hit", v != nil),
+		)
+	}
+
+	if errors.Is(err, ErrTombstone) {
+		endSpan(providerSpan, nil)
+	} else {
+		endSpan(providerSpan, err)
+	}
+
+	return v, err
+}
+
+// getAccepted reads keys missed for current model version with accepted older versions and upgrades found values,
+// returns them along with index of provider which served them.
+func (c *Cache[T, V]) getAccepted(
+	ctx context.Context,
+	keys []*Key[V],
+	modelVersion uint16,
+	o *callOptions,
+) (map[*Key[V]]*T, map[*Key[V]]int) {
+	values := map[*Key[V]]*T{}
+	indexes := map[*Key[V]]int{}
+
+	for _, version := range c.builder.acceptedVersions {
+		if version == modelVersion {
+			continue
+		}
+
+		for i, provider := range c.builder.providers {
+			if len(keys) == 0 {
+				return values, indexes
+			}
+
+			if o.skips(i) {
+				continue
+			}
+
+			found, _, err := provider.MGet(ctx, keys, version)
+			if err != nil {
+				c.builder.logger.Error(err, "can not get accepted version from provider",
+					slog.String("provider", providerName(provider)), keyCount(len(keys)))
+				continue
+			}
+
+			remaining := keys[:0:0]
+
+			for _, k := range keys {
+				v := found[k]
+				if v == nil { // missing or tombstone of older version
+					remaining = append(remaining, k)
+					continue
+				}
+
+				if c.builder.upgrader != nil {
+					if v, err = c.builder.upgrader(v, version); err != nil {
+						c.builder.logger.Error(err, "can not upgrade cached value",
+							slog.Int("version", int(version)), keyCount(1))
+						continue
+					}
+				}
+
+				values[k] = v
+				indexes[k] = i
 			}
+
+			keys = remaining
+		}
+	}
+
+	return values, indexes
+}
+
+// startSource starts span for source call, its context is bounded by source timeout when configured.
+func (c *Cache[T, V]) startSource(ctx context.Context) (context.Context, trace.Span, context.CancelFunc) {
+	ctx, span := c.startSpan(ctx, "cache.source")
+
+	if c.builder.sourceTimeout <= 0 {
+		return ctx, span, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.builder.sourceTimeout)
+
+	return ctx, span, cancel
+}
+
+func (c *Cache[T, V]) getSingleFromSource(
+	ctx context.Context,
+	key *Key[V],
+	fn GetSingleFromSourceFnWithTTL[T, V],
+) (*T, time.Duration, error) {
+	if !c.builder.singleflight {
+		return fn(ctx, key)
+	}
+
+	type sourceResult struct {
+		value *T
+		ttl   time.Duration
+	}
+
+	v, err, _ := c.group.Do(key.Key, func() (interface{}, error) {
+		// result is shared with concurrent callers, so cancellation of the one which started the call
+		// must not fail the rest, source timeout still bounds it
+		sharedCtx := detach(ctx)
+
+		if c.builder.sourceTimeout > 0 {
+			var cancel context.CancelFunc
+			sharedCtx, cancel = context.WithTimeout(sharedCtx, c.builder.sourceTimeout)
+			defer cancel()
 		}
+
+		value, ttl, err := fn(sharedCtx, key)
+
+		return sourceResult{value: value, ttl: ttl}, err
+	})
+
+	if err != nil && !errors.Is(err, ErrDoNotCache) {
+		return nil, 0, err
+	}
+
+	r := v.(sourceResult)
+
+	return r.value, r.ttl, err
+}
+
+// MGet returns values found in providers or source. Keys without value, either negatively cached or not
+// returned by source, are omitted from result, use MGetWithMissing to get them.
+// With WithPartialResults values found in providers are returned along with *SourceError on source failure.
+// Keys with equal Key are read and loaded once, every one of them gets the result.
+func (c *Cache[T, V]) MGet(ctx context.Context, keys []*Key[V], fn GetFromSourceFn[T, V], opts ...CallOption) (map[*Key[V]]*T, error) {
+	results, _, err := c.MGetWithMissing(ctx, keys, fn, opts...)
+
+	return results, err
+}
+
+// MGetSlice is MGet which returns values aligned with keys, nil where key has no value.
+func (c *Cache[T, V]) MGetSlice(ctx context.Context, keys []*Key[V], fn GetFromSourceFn[T, V], opts ...CallOption) ([]*T, error) {
+	results, err := c.MGet(ctx, keys, fn, opts...)
+	if results == nil {
+		return nil, err
+	}
+
+	values := make([]*T, len(keys))
+	for i, k := range keys {
+		values[i] = results[k]
+	}
+
+	return values, err
+}
+
+// MGetWithMissing is MGet which also returns keys that ended up without value. Keys not returned by source
+// are negatively cached when negative caching is enabled, so source is not queried for them until ttl.
+func (c *Cache[T, V]) MGetWithMissing(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFn[T, V],
+	opts ...CallOption,
+) (map[*Key[V]]*T, []*Key[V], error) {
+	if fn == nil {
+		fn = c.multiLoader(ctx)
+	}
+
+	results, meta, _, err := c.mget(ctx, keys, withoutKeyErrors(fn), opts)
+
+	return results, meta.NotFound, err
+}
+
+// MGetWithMeta is MGet which also reports how many keys were served by each provider and by source,
+// e.g. to tell whether faster provider is too small or ttl is too short.
+func (c *Cache[T, V]) MGetWithMeta(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFn[T, V],
+	opts ...CallOption,
+) (map[*Key[V]]*T, MGetMeta[V], error) {
+	if fn == nil {
+		fn = c.multiLoader(ctx)
+	}
+
+	results, meta, _, err := c.mget(ctx, keys, withoutKeyErrors(fn), opts)
+
+	return results, meta, err
+}
+
+// MGetWithErrors is MGet for source which reports failures per key. Values loaded for other keys
+// are returned and written back, failed keys are returned in key errors map and are neither cached nor
+// negatively cached. Default multi loader is used when fn is nil.
+func (c *Cache[T, V]) MGetWithErrors(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFnWithErrors[T, V],
+	opts ...CallOption,
+) (map[*Key[V]]*T, map[*Key[V]]error, error) {
+	if fn == nil {
+		fn = withoutKeyErrors(c.multiLoader(ctx))
+	}
+
+	results, _, keyErrs, err := c.mget(ctx, keys, fn, opts)
+
+	return results, keyErrs, err
+}
+
+func withoutKeyErrors[T, V any](fn GetFromSourceFn[T, V]) GetFromSourceFnWithErrors[T, V] {
+	if fn == nil {
+		return nil
 	}
 
-	return finalValue, nil
+	return func(ctx context.Context, keys []*Key[V]) (map[*Key[V]]*T, map[*Key[V]]error, error) {
+		values, err := fn(ctx, keys)
+
+		return values, nil, err
+	}
 }
 
-func (c *Cache[T, V]) MGet(ctx context.Context, keys []*Key[V], fn GetFromSourceFn[T, V]) (map[*Key[V]]*T, error) {
+func (c *Cache[T, V]) mget(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFnWithErrors[T, V],
+	opts []CallOption,
+) (map[*Key[V]]*T, MGetMeta[V], map[*Key[V]]error, error) {
+	meta := MGetMeta[V]{ProviderHits: make([]int, len(c.builder.providers))}
+
+	if c.closed.Load() {
+		return nil, meta, nil, errors.WithStack(ErrClosed)
+	}
+
+	keys, duplicates := dedupKeys(keys)
+
+	ctx, span := c.startSpan(ctx, "cache.MGet")
+	defer span.End()
+
+	o := c.callOptions(opts)
+	modelVersion := c.ModelVersion()
+
 	var missingIn []missingData[T, V]
+	var notFound []*Key[V]
 
 	finalResults := map[*Key[V]]*T{}
+	toWriteback := map[*Key[V]]*T{}
 	toQuery := keys
 
-	for _, provider := range c.builder.providers {
-		found, missing, err := provider.MGet(ctx, toQuery, c.builder.modelVersion)
+	for i, provider := range c.builder.providers {
+		if o.skips(i) {
+			missingIn = append(missingIn, missingData[T, V]{index: i, provider: provider, missingKeys: toQuery})
+			continue
+		}
+
+		providerCtx, providerSpan := c.startSpan(ctx, "cache.provider.MGet")
+		found, missing, err := provider.MGet(providerCtx, toQuery, modelVersion)
+
+		if providerSpan.IsRecording() {
+			providerSpan.SetAttributes(
+				attribute.String("cache.provider", providerName(provider)),
+				attribute.Int("cache.key_count", len(toQuery)),
+				attribute.Int("cache.hit_count", len(found)),
+			)
+		}
+
+		endSpan(providerSpan, err)
 
 		if err != nil {
-			zerolog.Ctx(ctx).Err(err).Send() // todo looks like cache is invalid
-			continue
+			c.builder.logger.Error(err, "can not get from provider", // todo looks like cache is invalid
+				slog.String("provider", providerName(provider)), keyCount(len(toQuery)))
+
+			found, missing = nil, toQuery // backfill queried keys once provider recovers
 		}
 
+		meta.ProviderHits[i] = len(found)
+
 		if len(missing) > 0 {
 			missingIn = append(missingIn, missingData[T, V]{
+				index:       i,
 				provider:    provider,
 				missingKeys: missing,
 			})
 		}
 
 		for k, v := range found {
+			if v == nil { // tombstone
+				notFound = append(notFound, k)
+				continue
+			}
+
 			finalResults[k] = v
+
+			// found in slower provider, backfill faster ones
+			if len(missingIn) > 0 && c.builder.readRepair && c.promotion.hit(k.Key) {
+				toWriteback[k] = v
+			}
 		}
 
 		toQuery = missing
@@ -86,52 +625,1027 @@ func (c *Cache[T, V]) MGet(ctx context.Context, keys []*Key[V], fn GetFromSource
 		}
 	}
 
-	var valuesFromSource map[*Key[V]]*T
+	if len(toQuery) > 0 && len(c.builder.acceptedVersions) > 0 {
+		values, indexes := c.getAccepted(ctx, toQuery, modelVersion, o)
+		remaining := toQuery[:0:0]
+
+		for _, k := range toQuery {
+			v, ok := values[k]
+			if !ok {
+				remaining = append(remaining, k)
+				continue
+			}
+
+			finalResults[k] = v
+			meta.ProviderHits[indexes[k]]++
+
+			if c.builder.upgrader != nil {
+				toWriteback[k] = v
+			}
+		}
+
+		toQuery = remaining
+	}
+
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.Int("cache.key_count", len(keys)),
+			attribute.Int("cache.hit_count", len(keys)-len(toQuery)),
+			attribute.Bool("cache.source_invoked", len(toQuery) > 0 && fn != nil),
+		)
+	}
+
+	c.recordHits(OperationMGet, len(keys)-len(toQuery))
+	c.recordMisses(OperationMGet, len(toQuery))
+
+	var absent []*Key[V]
+	var sourceErr error
+	var keyErrs map[*Key[V]]error
 
 	if len(toQuery) > 0 {
 		if fn == nil {
-			return nil, errors.New("get single from source is not defined")
+			return nil, meta, nil, errors.WithStack(ErrNoSourceFn)
 		}
 
-		newValues, err := fn(ctx, toQuery)
+		start := time.Now()
+		sourceCtx, sourceSpan, cancel := c.startSource(ctx)
 
-		if err != nil { // can not get from source
-			return nil, errors.Wrap(err, "can not get from source")
+		var newValues map[*Key[V]]*T
+		var failed map[*Key[V]]error
+		var err error
+
+		if c.builder.batchCoalescing {
+			newValues, failed, err = c.batches.load(sourceCtx, toQuery, fn, c.builder.sourceTimeout)
+		} else {
+			newValues, failed, err = fn(sourceCtx, toQuery)
 		}
 
-		valuesFromSource = newValues
-		for k, v := range newValues {
-			finalResults[k] = v
+		cancel()
+
+		doNotCache := errors.Is(err, ErrDoNotCache)
+		if doNotCache {
+			err = nil
 		}
-	}
 
-	if len(missingIn) > 0 && len(valuesFromSource) > 0 {
-		go func() {
-			for _, m := range missingIn {
-				toSet := map[string]*T{}
-				for _, k := range m.missingKeys {
-					if v, ok := valuesFromSource[k]; ok {
-						toSet[k.Key] = v
-					}
+		endSpan(sourceSpan, err)
+		c.recordSourceCall(OperationMGet, start, err)
+
+		if err != nil { // can not get from source
+			sourceErr = errors.WithStack(&SourceError{Err: err})
+
+			if !c.builder.partialResults {
+				return nil, meta, nil, sourceErr
+			}
+		} else {
+			keyErrs = failed
+
+			for k, v := range newValues {
+				if _, ok := failed[k]; ok {
+					continue
+				}
+
+				if v == nil { // explicit nil is not found, see absent below
+					continue
+				}
+
+				finalResults[k] = v
+				meta.FromSource++
+
+				if !doNotCache {
+					toWriteback[k] = v
+				}
+			}
+
+			for _, k := range toQuery {
+				if _, ok := failed[k]; ok {
+					continue
 				}
 
-				if err := m.provider.MSet(context.Background(), toSet, c.builder.ttl); err != nil { // coz async
-					zerolog.Ctx(ctx).Err(err).Send() // todo
+				if newValues[k] == nil {
+					absent = append(absent, k)
 				}
 			}
-		}()
+
+			notFound = append(notFound, absent...)
+
+			if doNotCache {
+				absent = nil
+			}
+		}
 	}
 
-	return finalResults, nil
-}
+	if c.NegativeTtl() <= 0 {
+		absent = nil
+	}
 
-func (c *Cache[T, V]) MSet(ctx context.Context, records map[string]*T) error {
-	var finalErr error
-	for _, m := range c.builder.providers {
-		if err := m.MSet(ctx, records, c.builder.ttl); err != nil {
-			finalErr = multierror.Append(finalErr, err)
+	syncWriteback := c.builder.syncWriteback
+
+	if c.writeBehind != nil && len(missingIn) > 0 && len(toWriteback) > 0 {
+		if c.writeBehind.add(missingIn, toWriteback, o.ttl) {
+			toWriteback = nil
+		} else { // closed concurrently, async writeback would be skipped as well
+			syncWriteback = true
 		}
 	}
 
-	return finalErr
+	if len(missingIn) > 0 && (len(toWriteback) > 0 || len(absent) > 0) {
+		if syncWriteback {
+			c.writeback(ctx, missingIn, toWriteback, absent, o.ttl)
+		} else {
+			c.async(func() {
+				c.writeback(detach(ctx), missingIn, toWriteback, absent, o.ttl)
+			})
+		}
+	}
+
+	if len(duplicates) > 0 {
+		notFound, keyErrs = fanOutDuplicates(duplicates, finalResults, notFound, keyErrs)
+	}
+
+	meta.NotFound = notFound
+
+	return finalResults, meta, keyErrs, sourceErr
+}
+
+// dedupKeys collapses keys with equal Key, so each is read and loaded once,
+// and returns other keys with the same Key per kept one.
+func dedupKeys[V any](keys []*Key[V]) ([]*Key[V], map[*Key[V]][]*Key[V]) {
+	seen := make(map[string]*Key[V], len(keys))
+
+	var unique []*Key[V]
+	var duplicates map[*Key[V]][]*Key[V]
+
+	for i, k := range keys {
+		first, ok := seen[k.Key]
+		if !ok {
+			seen[k.Key] = k
+
+			if unique != nil {
+				unique = append(unique, k)
+			}
+
+			continue
+		}
+
+		if unique == nil {
+			unique = append(make([]*Key[V], 0, len(keys)), keys[:i]...)
+			duplicates = map[*Key[V]][]*Key[V]{}
+		}
+
+		if first != k { // same pointer already gets the result
+			duplicates[first] = append(duplicates[first], k)
+		}
+	}
+
+	if unique == nil {
+		return keys, nil
+	}
+
+	return unique, duplicates
+}
+
+// fanOutDuplicates copies result of kept key to its duplicates, see dedupKeys.
+func fanOutDuplicates[T, V any](
+	duplicates map[*Key[V]][]*Key[V],
+	results map[*Key[V]]*T,
+	notFound []*Key[V],
+	keyErrs map[*Key[V]]error,
+) ([]*Key[V], map[*Key[V]]error) {
+	for first, dups := range duplicates {
+		if v, ok := results[first]; ok {
+			for _, k := range dups {
+				results[k] = v
+			}
+		}
+	}
+
+	for _, k := range notFound {
+		notFound = append(notFound, duplicates[k]...)
+	}
+
+	if len(keyErrs) > 0 {
+		withDups := make(map[*Key[V]]error, len(keyErrs))
+
+		for k, err := range keyErrs {
+			withDups[k] = err
+
+			for _, dup := range duplicates[k] {
+				withDups[dup] = err
+			}
+		}
+
+		keyErrs = withDups
+	}
+
+	return notFound, keyErrs
+}
+
+// Refresh skips provider reads, loads key from source and writes it to every provider before returning.
+// Nil value from source removes key from providers, or negatively caches it when negative caching is enabled.
+func (c *Cache[T, V]) Refresh(ctx context.Context, key *Key[V], fn GetSingleFromSourceFn[T, V]) (*T, error) {
+	if c.closed.Load() {
+		return nil, errors.WithStack(ErrClosed)
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.Refresh")
+	defer span.End()
+
+	if fn == nil {
+		fn = c.singleLoader(ctx)
+	}
+
+	if fn == nil {
+		return nil, errors.WithStack(ErrNoSourceFn)
+	}
+
+	start := time.Now()
+	sourceCtx, sourceSpan, cancel := c.startSource(ctx)
+
+	value, err := fn(sourceCtx, key)
+	cancel()
+
+	endSpan(sourceSpan, err)
+	c.recordSourceCall(OperationRefresh, start, err)
+
+	if err != nil {
+		return nil, errors.WithStack(&SourceError{Err: err})
+	}
+
+	if value != nil {
+		err = c.setProviders(ctx, map[string]*T{key.Key: value}, true)
+	} else {
+		err = c.forget(ctx, []*Key[V]{key})
+	}
+
+	if err != nil {
+		c.recordSetFailure(OperationRefresh)
+		return nil, errors.Wrap(err, "can not refresh providers")
+	}
+
+	return value, nil
+}
+
+// MRefresh is Refresh for batch of keys, keys not returned by source are treated as nil values.
+func (c *Cache[T, V]) MRefresh(ctx context.Context, keys []*Key[V], fn GetFromSourceFn[T, V]) (map[*Key[V]]*T, error) {
+	if c.closed.Load() {
+		return nil, errors.WithStack(ErrClosed)
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.MRefresh")
+	defer span.End()
+
+	if fn == nil {
+		fn = c.multiLoader(ctx)
+	}
+
+	if fn == nil {
+		return nil, errors.WithStack(ErrNoSourceFn)
+	}
+
+	if len(keys) == 0 {
+		return map[*Key[V]]*T{}, nil
+	}
+
+	start := time.Now()
+	sourceCtx, sourceSpan, cancel := c.startSource(ctx)
+
+	values, err := fn(sourceCtx, keys)
+	cancel()
+
+	endSpan(sourceSpan, err)
+	c.recordSourceCall(OperationMRefresh, start, err)
+
+	if err != nil {
+		return nil, errors.WithStack(&SourceError{Err: err})
+	}
+
+	toSet := map[string]*T{}
+	var absent []*Key[V]
+
+	for _, k := range keys {
+		if v := values[k]; v != nil {
+			toSet[k.Key] = v
+			continue
+		}
+
+		absent = append(absent, k)
+	}
+
+	var finalErr error
+
+	if len(toSet) > 0 {
+		if err = c.setProviders(ctx, toSet, true); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	if len(absent) > 0 {
+		if err = c.forget(ctx, absent); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	if finalErr != nil {
+		c.recordSetFailure(OperationMRefresh)
+		return nil, errors.Wrap(finalErr, "can not refresh providers")
+	}
+
+	return values, nil
+}
+
+// forget drops keys which source no longer has, so stale values are not served.
+func (c *Cache[T, V]) forget(ctx context.Context, keys []*Key[V]) error {
+	if c.NegativeTtl() <= 0 {
+		return c.Delete(ctx, keys...)
+	}
+
+	strKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		strKeys = append(strKeys, k.Key)
+	}
+
+	c.discardPending(strKeys...)
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		if err := m.SetTombstones(ctx, strKeys, c.ModelVersion(), c.NegativeTtl()); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	return finalErr
+}
+
+func (c *Cache[T, V]) writeback(
+	ctx context.Context,
+	missingIn []missingData[T, V],
+	values map[*Key[V]]*T,
+	absent []*Key[V],
+	ttl time.Duration,
+) {
+	absentSet := make(map[*Key[V]]struct{}, len(absent))
+	for _, k := range absent {
+		absentSet[k] = struct{}{}
+	}
+
+	for _, m := range missingIn {
+		if circuitOpen(m.provider) { // writes would fail fast anyway, backfilled once breaker closes
+			continue
+		}
+
+		toSet := map[string]*T{}
+		var toTombstone []string
+
+		for _, k := range m.missingKeys {
+			if v, ok := values[k]; ok {
+				toSet[k.Key] = v
+			}
+
+			if _, ok := absentSet[k]; ok {
+				toTombstone = append(toTombstone, k.Key)
+			}
+		}
+
+		if len(toTombstone) > 0 {
+			err := m.provider.SetTombstones(ctx, toTombstone, c.ModelVersion(), c.NegativeTtl())
+			if err != nil && !errors.Is(err, ErrCircuitOpen) {
+				c.recordSetFailure(OperationMGet)
+				c.builder.logger.Error(err, "can not set tombstone",
+					slog.String("provider", providerName(m.provider)), keyCount(len(toTombstone)))
+			}
+		}
+
+		if len(toSet) == 0 {
+			continue
+		}
+
+		c.writebackValues(ctx, m.provider, toSet, ttl)
+	}
+}
+
+// detach returns ctx for work outliving the call, values of ctx are kept, but its cancellation is not.
+func detach(ctx context.Context) context.Context {
+	if ctx.Done() == nil {
+		return ctx
+	}
+
+	return context.WithoutCancel(ctx)
+}
+
+// discardPending drops values of keys buffered by WithWriteBehind, so flush does not overwrite explicit writes.
+func (c *Cache[T, V]) discardPending(keys ...string) {
+	if c.writeBehind != nil {
+		c.writeBehind.discard(keys...)
+	}
+}
+
+func (c *Cache[T, V]) flushWriteBehind(pending map[writeBehindTarget]map[string]*T) {
+	for target, values := range pending {
+		c.writebackValues(context.Background(), c.builder.providers[target.index], values, target.ttl)
+	}
+}
+
+// writebackValues writes values found on MGet to provider which missed them, failure is reported
+// to writeback error handler or logged. Writes rejected by open circuit breaker are dropped silently.
+func (c *Cache[T, V]) writebackValues(ctx context.Context, provider Provider[T, V], values map[string]*T, ttl time.Duration) {
+	if err := c.msetProvider(ctx, provider, values, ttl); err != nil && !errors.Is(err, ErrCircuitOpen) {
+		c.recordSetFailure(OperationMGet)
+
+		if c.builder.writebackErrorHandler != nil {
+			c.builder.writebackErrorHandler(errors.Wrapf(err, "can not write back to provider %v",
+				providerName(provider)))
+			return
+		}
+
+		c.builder.logger.Error(err, "can not write back to provider",
+			slog.String("provider", providerName(provider)), keyCount(len(values)))
+	}
+}
+
+// configuredTtl is ttl of writes without explicit ttl, resolved per provider by resolveTtl.
+const configuredTtl = time.Duration(-1)
+
+// resolveTtl returns ttl of WithProviderTtl or builder ttl, in that order, when ttl is configuredTtl
+// and ttl itself otherwise. Explicit ttl comes from WithCallTtl or source, see GetWithTTLFromSource.
+func (c *Cache[T, V]) resolveTtl(provider Provider[T, V], ttl time.Duration) time.Duration {
+	if ttl >= 0 {
+		return ttl
+	}
+
+	return providerTtl(provider, c.Ttl())
+}
+
+// msetProvider writes values to provider grouped by ttl, entities implementing TTLProvider replace given ttl,
+// which is resolved by resolveTtl otherwise. Failure of some groups is reported as *FailedKeysError.
+func (c *Cache[T, V]) msetProvider(ctx context.Context, provider Provider[T, V], values map[string]*T, ttl time.Duration) error {
+	ttl = c.resolveTtl(provider, ttl)
+	groups := map[time.Duration]map[string]*T{}
+
+	for k, v := range values {
+		entityTtl := ttl
+
+		if v != nil {
+			p, ok := any(*v).(TTLProvider)
+			if !ok {
+				p, ok = any(v).(TTLProvider) // GetCacheTTL with pointer receiver
+			}
+
+			if ok && p.GetCacheTTL() > 0 {
+				entityTtl = p.GetCacheTTL()
+			}
+		}
+
+		if groups[entityTtl] == nil {
+			groups[entityTtl] = map[string]*T{}
+		}
+
+		groups[entityTtl][k] = v
+	}
+
+	if len(groups) <= 1 {
+		for groupTtl := range groups {
+			ttl = groupTtl
+		}
+
+		return provider.MSet(ctx, values, ttl)
+	}
+
+	var multiErr error
+	var failed []string
+
+	for groupTtl, group := range groups {
+		err := provider.MSet(ctx, group, groupTtl)
+		if err == nil {
+			continue
+		}
+
+		multiErr = multierror.Append(multiErr, err)
+
+		var failedKeys *FailedKeysError
+		if errors.As(err, &failedKeys) {
+			failed = append(failed, failedKeys.Keys...)
+		} else {
+			failed = append(failed, sortedKeys(group)...)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	sort.Strings(failed)
+
+	return &FailedKeysError{Keys: failed, Err: multiErr}
+}
+
+// Exists reports whether any provider stores key, see Provider.Exists for stale entries caveat.
+// Errors are returned only when no provider reported key.
+func (c *Cache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if c.closed.Load() {
+		return false, errors.WithStack(ErrClosed)
+	}
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		ok, err := m.Exists(ctx, key)
+		if err != nil {
+			finalErr = multierror.Append(finalErr, err)
+			continue
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, finalErr
+}
+
+// Update atomically replaces value of key with result of fn, which receives current value or nil when key is missing.
+// It runs on the slowest provider implementing Updater, the key is deleted from other providers afterwards,
+// so they reload it instead of keeping value written by concurrent update. Nil returned by fn deletes key.
+// fn may be called several times on conflicting updates and must not have side effects.
+func (c *Cache[T, V]) Update(
+	ctx context.Context,
+	key *Key[V],
+	fn func(current *T) (*T, error),
+	opts ...CallOption,
+) (*T, error) {
+	if c.closed.Load() {
+		return nil, errors.WithStack(ErrClosed)
+	}
+
+	c.discardPending(key.Key)
+
+	updaterIndex := -1
+	var updater Updater[T, V]
+
+	for i := len(c.builder.providers) - 1; i >= 0; i-- {
+		if u, ok := UnwrapProvider(c.builder.providers[i]).(Updater[T, V]); ok {
+			updaterIndex, updater = i, u
+			break
+		}
+	}
+
+	if updater == nil {
+		return nil, errors.WithStack(ErrUpdateNotSupported)
+	}
+
+	o := c.callOptions(opts)
+	ttl := c.resolveTtl(c.builder.providers[updaterIndex], o.ttl)
+
+	v, err := updater.Update(ctx, key, c.ModelVersion(), ttl, func(current *T) (*T, error) {
+		next, err := fn(current)
+		if err != nil || next == nil || !c.builder.strictVersion {
+			return next, err
+		}
+
+		return next, c.checkModelVersion(key.Key, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var finalErr error
+	for i, m := range c.builder.providers {
+		if i == updaterIndex {
+			continue
+		}
+
+		if err = m.Delete(ctx, key); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	return v, finalErr
+}
+
+// DeleteByPrefix removes keys starting with prefix from every provider implementing PrefixDeleter,
+// i.e. LRUCache, RedisCache and RedisHashCache, and returns amount of removed entries summed over providers.
+// On redis it is best-effort and not atomic, keys written concurrently may survive.
+func (c *Cache[T, V]) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if c.closed.Load() {
+		return 0, errors.WithStack(ErrClosed)
+	}
+
+	supported := false
+	removed := 0
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		deleter, ok := UnwrapProvider(m).(PrefixDeleter)
+		if !ok {
+			continue
+		}
+
+		supported = true
+
+		n, err := deleter.DeleteByPrefix(ctx, prefix)
+		removed += n
+
+		if err != nil {
+			finalErr = multierror.Append(finalErr, errors.Wrapf(err, "can not delete by prefix from provider %v",
+				providerName(m)))
+		}
+	}
+
+	if !supported {
+		return 0, errors.WithStack(ErrDeleteByPrefixNotSupported)
+	}
+
+	return removed, finalErr
+}
+
+// TTL returns the longest remaining ttl of key across providers implementing TTLReader, TTLNoExpiry when
+// some provider stores it without expiration and TTLNotFound when no provider has it. Negatively cached key
+// is present. Providers which fail are skipped, their errors are returned along with ttl of the rest.
+func (c *Cache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	if c.closed.Load() {
+		return TTLNotFound, errors.WithStack(ErrClosed)
+	}
+
+	supported := false
+	ttl := TTLNotFound
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		reader, ok := UnwrapProvider(m).(TTLReader[V])
+		if !ok {
+			continue
+		}
+
+		supported = true
+
+		providerTtl, err := reader.TTL(ctx, key)
+		if err != nil {
+			finalErr = multierror.Append(finalErr, errors.Wrapf(err, "can not get ttl from provider %v",
+				providerName(m)))
+			continue
+		}
+
+		ttl = longerTtl(ttl, providerTtl)
+	}
+
+	if !supported {
+		return TTLNotFound, errors.WithStack(ErrTTLNotSupported)
+	}
+
+	return ttl, finalErr
+}
+
+// longerTtl returns longer of remaining ttls, TTLNoExpiry is longer than any ttl and TTLNotFound shorter.
+func longerTtl(a, b time.Duration) time.Duration {
+	if a == TTLNoExpiry || b == TTLNoExpiry {
+		return TTLNoExpiry
+	}
+
+	return max(a, b)
+}
+
+// Ping checks every provider implementing Pinger, others are considered healthy.
+// Returned error names each unhealthy provider, so it can be reported by readiness probe as is.
+func (c *Cache[T, V]) Ping(ctx context.Context) error {
+	var finalErr error
+
+	for i, m := range c.builder.providers {
+		pinger, ok := UnwrapProvider(m).(Pinger)
+		if !ok {
+			continue
+		}
+
+		if err := pinger.Ping(ctx); err != nil {
+			finalErr = multierror.Append(finalErr, errors.Wrapf(err, "provider %v (%v) is unhealthy", i, providerName(m)))
+		}
+	}
+
+	return finalErr
+}
+
+// NewKey creates key for original value using KeyFunc set by WithKeyFunc.
+func (c *Cache[T, V]) NewKey(original V) *Key[V] {
+	if c.builder.keyFunc == nil {
+		return &Key[V]{Key: fmt.Sprint(original), OriginalValue: original}
+	}
+
+	return &Key[V]{Key: c.builder.keyFunc(original), OriginalValue: original}
+}
+
+// ModelVersion returns model version required from cached entities.
+func (c *Cache[T, V]) ModelVersion() uint16 {
+	return uint16(c.modelVersion.Load())
+}
+
+// SetModelVersion changes required model version while cache is in use, entries of other versions
+// are treated as missing on next read and refetched from source. Safe for concurrent use.
+func (c *Cache[T, V]) SetModelVersion(v uint16) {
+	c.modelVersion.Store(uint32(v))
+}
+
+// Ttl returns ttl of written values, see Builder.WithTtl.
+func (c *Cache[T, V]) Ttl() time.Duration {
+	return time.Duration(c.ttl.Load())
+}
+
+// SetTtl changes ttl of values written after the call. Safe for concurrent use.
+func (c *Cache[T, V]) SetTtl(ttl time.Duration) {
+	if ttl < 0 {
+		ttl = DefaultTtl
+	}
+
+	c.ttl.Store(int64(ttl))
+}
+
+// NegativeTtl returns ttl of tombstones, zero means negative caching is disabled, see Builder.WithNegativeCaching.
+func (c *Cache[T, V]) NegativeTtl() time.Duration {
+	return time.Duration(c.negativeTtl.Load())
+}
+
+// SetNegativeTtl changes ttl of tombstones written after the call, zero disables negative caching.
+// Safe for concurrent use.
+func (c *Cache[T, V]) SetNegativeTtl(ttl time.Duration) {
+	c.negativeTtl.Store(int64(max(ttl, 0)))
+}
+
+// Set writes single value to all providers with builder ttl, see WithBackfillOnly and MSet.
+func (c *Cache[T, V]) Set(ctx context.Context, key *Key[V], value *T) error {
+	return c.MSet(ctx, map[string]*T{
+		key.Key: value,
+	})
+}
+
+// Warm preloads items into all providers with builder ttl, nil values are skipped.
+// Nothing is written when any entity has model version different from builder one.
+func (c *Cache[T, V]) Warm(ctx context.Context, items map[*Key[V]]*T) error {
+	records := make(map[string]*T, len(items))
+
+	for key, item := range items {
+		if item == nil {
+			continue
+		}
+
+		if err := c.checkModelVersion(key.Key, item); err != nil {
+			return err
+		}
+
+		records[key.Key] = item
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	return c.setProviders(ctx, records, true)
+}
+
+func (c *Cache[T, V]) checkModelVersion(key string, item *T) error {
+	if item == nil {
+		return nil
+	}
+
+	modelVersion := c.ModelVersion()
+
+	if e, ok := any(*item).(Entity); ok && !versionMatches(e.GetCacheModelVersion(), modelVersion) {
+		return errors.Wrapf(ErrModelVersionMismatch, "key %v has model version %v, expected %v",
+			key, e.GetCacheModelVersion(), modelVersion)
+	}
+
+	return nil
+}
+
+// MSet writes records to all providers, on failure *MSetError lists keys which were not written per provider.
+// Records are deleted from providers marked with WithBackfillOnly instead.
+// With WithStrictVersionOnSet nothing is written when any entity has model version different from builder one.
+// With Builder.WithWriteThrough records are persisted to source as well, see WithWriteThroughCacheFirst for order.
+func (c *Cache[T, V]) MSet(ctx context.Context, records map[string]*T) error {
+	writer := c.builder.writeThrough
+	if writer == nil || c.closed.Load() {
+		return c.setProviders(ctx, records, false)
+	}
+
+	if c.builder.strictVersion { // rejected records must not reach source either
+		for key, item := range records {
+			if err := c.checkModelVersion(key, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !c.builder.writeThroughCacheFirst {
+		if err := writer(ctx, records); err != nil {
+			return errors.Wrap(err, "can not write to source")
+		}
+
+		if err := c.setProviders(ctx, records, false); err != nil {
+			// providers which failed may still serve previous values, which source no longer has
+			c.invalidate(ctx, failedKeys(err, records))
+			return err
+		}
+
+		return nil
+	}
+
+	if err := c.setProviders(ctx, records, false); err != nil {
+		// values are not persisted, so providers which wrote them must not serve them
+		c.invalidate(ctx, sortedKeys(records))
+		return err
+	}
+
+	if err := writer(ctx, records); err != nil {
+		c.invalidate(ctx, sortedKeys(records))
+		return errors.Wrap(err, "can not write to source")
+	}
+
+	return nil
+}
+
+// invalidate is best-effort deletion of keys whose cached values diverged from source, see Builder.WithWriteThrough.
+func (c *Cache[T, V]) invalidate(ctx context.Context, keys []string) {
+	toDelete := make([]*Key[V], 0, len(keys))
+	for _, k := range keys {
+		toDelete = append(toDelete, &Key[V]{Key: k})
+	}
+
+	if err := c.Delete(ctx, toDelete...); err != nil {
+		c.builder.logger.Error(err, "can not delete values diverged from source", keyCount(len(toDelete)))
+	}
+}
+
+// failedKeys returns keys listed by *MSetError, all keys of records for other errors.
+func failedKeys[T any](err error, records map[string]*T) []string {
+	var msetErr *MSetError
+	if !errors.As(err, &msetErr) {
+		return sortedKeys(records)
+	}
+
+	seen := map[string]struct{}{}
+
+	var keys []string
+	for _, f := range msetErr.Failures {
+		for _, k := range f.Keys {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	return keys
+}
+
+// setProviders writes records to providers only, used for values which already come from source.
+// Records written by user, i.e. not fromSource, are deleted from providers marked with WithBackfillOnly instead.
+func (c *Cache[T, V]) setProviders(ctx context.Context, records map[string]*T, fromSource bool) error {
+	if c.closed.Load() {
+		return errors.WithStack(ErrClosed)
+	}
+
+	if c.builder.strictVersion {
+		for key, item := range records {
+			if err := c.checkModelVersion(key, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.writeBehind != nil {
+		keys := make([]string, 0, len(records))
+		for k := range records {
+			keys = append(keys, k)
+		}
+
+		c.discardPending(keys...)
+	}
+
+	var failures []ProviderFailure
+	var backfillKeys []*Key[V]
+
+	for i, m := range c.builder.providers {
+		if fromSource || !isBackfillOnly(m) {
+			if err := c.msetProvider(ctx, m, records, configuredTtl); err != nil {
+				failures = append(failures, newProviderFailure(i, m, records, err))
+			}
+
+			continue
+		}
+
+		if backfillKeys == nil {
+			backfillKeys = make([]*Key[V], 0, len(records))
+			for k := range records {
+				backfillKeys = append(backfillKeys, &Key[V]{Key: k})
+			}
+		}
+
+		if err := m.Delete(ctx, backfillKeys...); err != nil {
+			failures = append(failures, newProviderFailure(i, m, records, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &MSetError{Failures: failures}
+}
+
+func (c *Cache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	if c.closed.Load() {
+		return errors.WithStack(ErrClosed)
+	}
+
+	if c.writeBehind != nil {
+		strKeys := make([]string, 0, len(keys))
+		for _, k := range keys {
+			strKeys = append(strKeys, k.Key)
+		}
+
+		c.discardPending(strKeys...)
+	}
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		if err := m.Delete(ctx, keys...); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	return finalErr
+}
+
+// MDelete removes batch of keys from all providers, providers split it into chunks on their own.
+// Failure of one provider does not prevent deletion in others, errors are aggregated.
+func (c *Cache[T, V]) MDelete(ctx context.Context, keys []*Key[V]) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.Delete(ctx, keys...)
+}
+
+// Clear clears every provider, failures are combined. Providers which can not be cleared report
+// ErrClearNotSupported, check it with errors.Is to tell it apart from backend failure.
+func (c *Cache[T, V]) Clear(ctx context.Context) error {
+	if c.closed.Load() {
+		return errors.WithStack(ErrClosed)
+	}
+
+	if c.writeBehind != nil {
+		c.writeBehind.take()
+	}
+
+	var finalErr error
+	for _, m := range c.builder.providers {
+		if err := m.Clear(ctx); err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	return finalErr
+}
+
+// Close stops refreshes of RegisterRefresh, flushes write-behind buffer and waits for in-flight async writebacks
+// until ctx is done, any operation after Close returns ErrClosed.
+func (c *Cache[T, V]) Close(ctx context.Context) error {
+	c.asyncMut.Lock()
+	c.closed.Store(true)
+	c.asyncMut.Unlock()
+
+	c.refreshers.close()
+
+	if c.writeBehind != nil {
+		if err := c.writeBehind.close(ctx); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		c.asyncWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// async runs fn in background tracked by Close, fn is skipped when cache is closed.
+func (c *Cache[T, V]) async(fn func()) {
+	c.asyncMut.Lock()
+	defer c.asyncMut.Unlock()
+
+	if c.closed.Load() {
+		return
+	}
+
+	c.asyncWg.Add(1)
+
+	go func() {
+		defer c.asyncWg.Done()
+
+		fn()
+	}()
 }
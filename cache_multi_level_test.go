@@ -0,0 +1,1025 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMultiLevelCacheDelete(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	l1.EXPECT().Delete(context.TODO(), key).Return(errors.New("l1 is down"))
+	l2.EXPECT().Delete(context.TODO(), key).Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		Build()
+
+	err := ch.Delete(context.TODO(), key)
+
+	assert.ErrorContains(t, err, "l1 is down")
+	l1.AssertExpectations(t)
+	l2.AssertExpectations(t)
+}
+
+func TestMultiLevelCacheMDelete(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := newMockProvider[EntityToCache, int](t)
+
+	keys := []*Key[int]{{Key: "key1", OriginalValue: 1}, {Key: "key2", OriginalValue: 2}}
+
+	l1.EXPECT().Delete(context.TODO(), keys[0], keys[1]).Return(errors.New("l1 is down"))
+	l2.EXPECT().Delete(context.TODO(), keys[0], keys[1]).Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		Build()
+
+	assert.ErrorContains(t, ch.MDelete(context.TODO(), keys), "l1 is down")
+	assert.Nil(t, ch.MDelete(context.TODO(), nil))
+	l1.AssertExpectations(t)
+	l2.AssertExpectations(t)
+}
+
+func TestMultiLevelCacheExists(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := newMockProvider[EntityToCache, int](t)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	l1.EXPECT().Exists(context.TODO(), key).Return(false, errors.New("l1 is down"))
+	l2.EXPECT().Exists(context.TODO(), key).Return(true, nil).Once()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		Build()
+
+	ok, err := ch.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	l2.EXPECT().Exists(context.TODO(), key).Return(false, nil).Once()
+
+	ok, err = ch.Exists(context.TODO(), key)
+	assert.ErrorContains(t, err, "l1 is down")
+	assert.False(t, ok)
+	l1.AssertExpectations(t)
+	l2.AssertExpectations(t)
+}
+
+func TestMultiLevelCacheMSetReportsFailedKeys(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := newMockProvider[EntityToCache, int](t)
+	l3 := newMockProvider[EntityToCache, int](t)
+
+	records := map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+		"key2": {Id: 2, ModelVersion: currentModelVersion},
+	}
+
+	l1.EXPECT().MSet(context.TODO(), records, mock.Anything).
+		Return(&FailedKeysError{Keys: []string{"key2"}, Err: errors.New("l1 chunk failed")})
+	l2.EXPECT().MSet(context.TODO(), records, mock.Anything).Return(nil)
+	l3.EXPECT().MSet(context.TODO(), records, mock.Anything).Return(errors.New("l3 is down"))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2, l3).
+		Build()
+
+	err := ch.MSet(context.TODO(), records)
+	assert.ErrorContains(t, err, "l1 chunk failed")
+	assert.ErrorContains(t, err, "l3 is down")
+
+	var msetErr *MSetError
+	assert.True(t, errors.As(err, &msetErr))
+	assert.Equal(t, 2, len(msetErr.Failures))
+	assert.Equal(t, 0, msetErr.Failures[0].ProviderIndex)
+	assert.Equal(t, []string{"key2"}, msetErr.Failures[0].Keys)
+	assert.Equal(t, 2, msetErr.Failures[1].ProviderIndex)
+	assert.Equal(t, "cache.mockProvider", msetErr.Failures[1].Provider)
+	assert.Equal(t, []string{"key1", "key2"}, msetErr.Failures[1].Keys)
+}
+
+func TestMultiLevelCacheClear(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := newMockProvider[EntityToCache, int](t)
+
+	l1.EXPECT().Clear(context.TODO()).Return(nil)
+	l2.EXPECT().Clear(context.TODO()).Return(errors.New("l2 is down"))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		Build()
+
+	err := ch.Clear(context.TODO())
+
+	assert.ErrorContains(t, err, "l2 is down")
+	l1.AssertExpectations(t)
+	l2.AssertExpectations(t)
+}
+
+func TestTieredCacheL2HitPopulatesL1(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	c := NewTieredCache[EntityToCache, int](currentModelVersion, l1, l2).
+		WithSyncWriteback(true).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, 0))
+
+	v, err := c.Get(context.TODO(), key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	resp, err := c.MGet(context.TODO(), []*Key[int]{key2}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, resp[key2].Id)
+
+	found, missing, err := l1.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, 1, found[key1].Id)
+	assert.Equal(t, 2, found[key2].Id)
+}
+
+func TestMultiLevelCacheGetWithMeta(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewMapCache[EntityToCache, int]()
+	l2 := NewMapCache[EntityToCache, int]()
+
+	c := NewTieredCache[EntityToCache, int](currentModelVersion, l1, l2).Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	source := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	}
+
+	v, meta, err := c.GetWithMeta(context.TODO(), key1, source)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+	assert.Equal(t, GetResult{Hit: true, ProviderIndex: 1}, meta)
+
+	_, meta, err = c.GetWithMeta(context.TODO(), key1, source)
+	assert.Nil(t, err)
+	assert.Equal(t, GetResult{Hit: true, ProviderIndex: 0}, meta)
+
+	v, meta, err = c.GetWithMeta(context.TODO(), key2, source)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+	assert.Equal(t, GetResult{ProviderIndex: -1, FromSource: true}, meta)
+}
+
+func TestMultiLevelCacheProviderTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewLRUCache[EntityToCache, int](10, time.Hour)
+
+	for _, lru := range []*LRUCache[EntityToCache, int]{l1, l2} {
+		lru.now = func() time.Time {
+			return now
+		}
+	}
+
+	c := NewTieredCache[EntityToCache, int](currentModelVersion, WithProviderTtl[EntityToCache, int](l1, 30*time.Second), l2).
+		WithTtl(time.Hour).
+		Build()
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	_, err := c.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+
+	now = now.Add(time.Minute)
+
+	v, err := l1.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	v, err = l2.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	assert.Equal(t, "cache.LRUCache", providerName(WithProviderTtl[EntityToCache, int](l1, time.Second)))
+}
+
+func TestUnwrapProvider(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	wrapped := WithBackfillOnly(WithProviderTtl(NewRedisCache[EntityToCache, int](client), time.Minute))
+
+	_, ok := wrapped.(Incrementer[int])
+	assert.False(t, ok) // wrappers hide capabilities
+
+	incrementer, ok := UnwrapProvider(wrapped).(Incrementer[int])
+	assert.True(t, ok)
+
+	v, err := incrementer.Increment(context.TODO(), &Key[int]{Key: "counter"}, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), v)
+}
+
+func TestWarm(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	c := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	err := c.Warm(context.TODO(), map[*Key[int]]*EntityToCache{
+		key1: {Id: 1, ModelVersion: currentModelVersion},
+		key2: {Id: 2, ModelVersion: currentModelVersion - 1},
+	})
+	assert.ErrorIs(t, err, ErrModelVersionMismatch)
+	assert.Equal(t, 0, l1.Len())
+	assert.Equal(t, 0, l2.Len())
+
+	assert.Nil(t, c.Warm(context.TODO(), map[*Key[int]]*EntityToCache{
+		key1: {Id: 1, ModelVersion: currentModelVersion},
+		key2: nil,
+	}))
+
+	for _, p := range []Provider[EntityToCache, int]{l1, l2} {
+		v, err := p.Get(context.TODO(), key1, currentModelVersion)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v.Id)
+	}
+	assert.Equal(t, 1, l2.Len())
+}
+
+func TestMultiLevelCacheMGetBackfillsFailedProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	l1 := newMockProvider[EntityToCache, int](t)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	l1.EXPECT().MGet(mock.Anything, []*Key[int]{key1, key2}, currentModelVersion).
+		Return(nil, nil, errors.New("l1 is down"))
+	l1.EXPECT().MSet(mock.Anything, map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Hour).Return(nil)
+
+	logger := &recordingLogger{}
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		WithTtl(time.Hour).
+		WithSyncWriteback(true).
+		WithLogger(logger).
+		Build()
+
+	resp, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		assert.Equal(t, []*Key[int]{key2}, keys)
+
+		return map[*Key[int]]*EntityToCache{
+			key2: {Id: 2, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, resp[key1].Id)
+	assert.Equal(t, 2, resp[key2].Id)
+	assert.Equal(t, []string{"can not get from provider"}, logger.msgs)
+
+	v, err := l2.Get(context.TODO(), key2, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+}
+
+func TestMultiLevelCacheMustBuild(t *testing.T) {
+	assert.PanicsWithValue(t, ErrNoProviders, func() {
+		NewCacheBuilder[EntityToCache, int](1).MustBuild()
+	})
+
+	assert.NotPanics(t, func() {
+		NewCacheBuilder[EntityToCache, int](1, NewMapCache[EntityToCache, int]()).MustBuild()
+	})
+}
+
+func TestMultiLevelCacheWithoutReadRepair(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, 0))
+
+	ch := NewTieredCache[EntityToCache, int](currentModelVersion, l1, l2).
+		WithSyncWriteback(true).
+		WithReadRepair(false).
+		Build()
+
+	v, err := ch.Get(context.TODO(), key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	resp, err := ch.MGet(context.TODO(), []*Key[int]{key2, key3}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key3: {Id: 3, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, resp[key2].Id)
+	assert.Equal(t, 3, resp[key3].Id)
+
+	assert.Equal(t, []string{key3.Key}, l1.Entries())
+	assert.Equal(t, 3, l2.Len())
+}
+
+// blockingProvider is MapCache which Get blocks until context is cancelled.
+type blockingProvider struct {
+	*MapCache[EntityToCache, int]
+}
+
+func (b blockingProvider) Get(ctx context.Context, key *Key[int], requiredModelVersion uint16) (*EntityToCache, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestMultiLevelCacheParallelReads(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	slow := blockingProvider{MapCache: NewMapCache[EntityToCache, int]()}
+	hit := NewMapCache[EntityToCache, int]()
+	miss := NewMapCache[EntityToCache, int]()
+
+	assert.Nil(t, hit.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	logger := &recordingLogger{}
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, slow, hit, miss).
+		WithParallelReads(true).
+		WithLogger(logger).
+		Build()
+
+	v, meta, err := ch.GetWithMeta(context.TODO(), key, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+	assert.Equal(t, GetResult{Hit: true, ProviderIndex: 1}, meta)
+
+	assert.Equal(t, 0, slow.Len())
+	assert.Equal(t, 1, miss.Len())
+	assert.Empty(t, logger.msgs)
+}
+
+// countingProvider is MapCache which counts MSet calls.
+type countingProvider struct {
+	*MapCache[EntityToCache, int]
+	msetCalls *atomic.Int32
+}
+
+func (c countingProvider) MSet(ctx context.Context, values map[string]*EntityToCache, ttl time.Duration) error {
+	c.msetCalls.Add(1)
+
+	return c.MapCache.MSet(ctx, values, ttl)
+}
+
+func TestMultiLevelCacheWriteBehind(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	fn := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		res := map[*Key[int]]*EntityToCache{}
+		for _, k := range keys {
+			res[k] = &EntityToCache{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+		}
+		return res, nil
+	}
+
+	for _, tc := range []struct {
+		name          string
+		writeBehind   bool
+		expectedCalls int32
+	}{
+		{name: "per call", expectedCalls: 10},
+		{name: "write behind", writeBehind: true, expectedCalls: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := countingProvider{MapCache: NewMapCache[EntityToCache, int](), msetCalls: &atomic.Int32{}}
+
+			b := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).WithSyncWriteback(true)
+			if tc.writeBehind {
+				b.WithWriteBehind(time.Hour, 100)
+			}
+
+			ch := b.Build()
+
+			for i := 0; i < 10; i++ {
+				_, err := ch.MGet(context.TODO(), []*Key[int]{{Key: fmt.Sprint(i), OriginalValue: i}}, fn)
+				assert.Nil(t, err)
+			}
+
+			assert.Nil(t, ch.Close(context.TODO()))
+			assert.Equal(t, tc.expectedCalls, provider.msetCalls.Load())
+			assert.Equal(t, 10, provider.Len())
+		})
+	}
+}
+
+func TestMultiLevelCacheWriteBehindFlushesFullBatch(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithWriteBehind(time.Hour, 2).
+		Build()
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{{Key: "1", OriginalValue: 1}, {Key: "2", OriginalValue: 2}},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			res := map[*Key[int]]*EntityToCache{}
+			for _, k := range keys {
+				res[k] = &EntityToCache{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+			}
+			return res, nil
+		})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return provider.Len() == 2
+	}, time.Second, time.Millisecond)
+
+	assert.Nil(t, ch.Close(context.TODO()))
+}
+
+func TestMultiLevelCacheWriteBehindStoppedWritesSynchronously(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithWriteBehind(time.Hour, 100).
+		Build()
+
+	// write-behind is stopped by concurrent Close after MGet passed closed check
+	assert.Nil(t, ch.writeBehind.close(context.TODO()))
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{{Key: "1", OriginalValue: 1}},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			res := map[*Key[int]]*EntityToCache{}
+			for _, k := range keys {
+				res[k] = &EntityToCache{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+			}
+			return res, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, provider.Len())
+}
+
+func TestMultiLevelCacheWriteBehindExplicitWritesWin(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithWriteBehind(time.Hour, 100).
+		Build()
+
+	key1 := &Key[int]{Key: "1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "2", OriginalValue: 2}
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			res := map[*Key[int]]*EntityToCache{}
+			for _, k := range keys {
+				res[k] = &EntityToCache{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+			}
+			return res, nil
+		})
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Set(context.TODO(), key1, &EntityToCache{Id: 10, ModelVersion: currentModelVersion}))
+	assert.Nil(t, ch.Delete(context.TODO(), key2))
+
+	assert.Nil(t, ch.Close(context.TODO()))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, v.Id) // buffered value does not overwrite explicit write
+
+	exists, err := provider.Exists(context.TODO(), key2)
+	assert.Nil(t, err)
+	assert.False(t, exists) // deleted key is not resurrected by flush
+}
+
+func TestMultiLevelCacheUpdate(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewRedisCache[EntityToCache, int](client)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).Build()
+	key := &Key[int]{Key: "counter", OriginalValue: 1}
+
+	assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: -1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := ch.Update(context.TODO(), key, func(current *EntityToCache) (*EntityToCache, error) {
+				next := &EntityToCache{Id: 1, ModelVersion: currentModelVersion}
+				if current != nil {
+					next.Id = current.Id + 1
+				}
+
+				return next, nil
+			})
+			assert.Nil(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	v, err := ch.Get(context.TODO(), key, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, v.Id)
+
+	_, err = NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		Build().
+		Update(context.TODO(), key, nil)
+	assert.ErrorIs(t, err, ErrUpdateNotSupported)
+}
+
+func TestMultiLevelCacheUpdateWrappedProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion,
+		WithBackfillOnly(WithProviderTtl(NewRedisCache[EntityToCache, int](client), time.Minute)),
+	).Build()
+	key := &Key[int]{Key: "counter", OriginalValue: 1}
+
+	v, err := ch.Update(context.TODO(), key, func(current *EntityToCache) (*EntityToCache, error) {
+		return &EntityToCache{Id: 1, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+	assert.Equal(t, time.Minute, srv.TTL(key.Key)) // ttl of WithProviderTtl is used
+}
+
+func TestMultiLevelCachePing(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	ch := NewCacheBuilder[EntityToCache, int](7,
+		NewLRUCache[EntityToCache, int](10, time.Hour),
+		WithProviderTtl(NewRedisCache[EntityToCache, int](client), time.Minute),
+		NewMapCache[EntityToCache, int](),
+	).Build()
+
+	assert.Nil(t, ch.Ping(context.TODO()))
+
+	srv.Close()
+
+	err := ch.Ping(context.TODO())
+	assert.ErrorContains(t, err, "provider 1 (cache.RedisCache) is unhealthy")
+	assert.NotContains(t, err.Error(), "LRUCache")
+}
+
+func TestMultiLevelCacheBackfillOnly(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewRedisCache[EntityToCache, int](client)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, WithBackfillOnly[EntityToCache, int](l1), l2).
+		WithSyncWriteback(true).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: -1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	assert.Nil(t, ch.Set(context.TODO(), key1, &EntityToCache{Id: 1, ModelVersion: currentModelVersion}))
+	assert.True(t, srv.Exists(key1.Key))
+	assert.Equal(t, 0, l1.Len())
+
+	_, err := ch.Get(context.TODO(), key2, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+
+	v, err := l1.Get(context.TODO(), key2, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+	assert.True(t, srv.Exists(key2.Key))
+}
+
+func TestMultiLevelCacheBackfillOnlyRefresh(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, WithBackfillOnly[EntityToCache, int](l1), l2).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: -1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	_, err := ch.Refresh(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+
+	v, err := l1.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id) // refreshed value replaces stale one instead of deleting it
+
+	assert.Nil(t, ch.Warm(context.TODO(), map[*Key[int]]*EntityToCache{
+		key2: {Id: 2, ModelVersion: currentModelVersion},
+	}))
+
+	v, err = l1.Get(context.TODO(), key2, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+}
+
+func TestMultiLevelCacheFailOnProviderError(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	hook := &failingHook{}
+	client.AddHook(hook)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, NewRedisCache[EntityToCache, int](client)).
+		WithFailOnProviderError(true).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	sourceCalls := 0
+
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		sourceCalls++
+
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	}
+
+	hook.failures = 1
+	_, err := ch.Get(context.TODO(), key1, fn)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorContains(t, err, "can not get from provider cache.RedisCache")
+
+	assert.Equal(t, 0, sourceCalls)
+
+	assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	v, err := ch.Get(context.TODO(), key1, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	v, err = ch.Get(context.TODO(), key2, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+	assert.Equal(t, 1, sourceCalls)
+}
+
+func TestMultiLevelCachePromotionThreshold(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewMapCache[EntityToCache, int]()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		WithSyncWriteback(true).
+		WithPromotionThreshold(3).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		_, err := ch.Get(context.TODO(), key1, nil)
+		assert.Nil(t, err)
+
+		_, err = ch.MGet(context.TODO(), []*Key[int]{key2}, nil)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 0, l1.Len())
+	}
+
+	_, err := ch.Get(context.TODO(), key1, nil)
+	assert.Nil(t, err)
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key2}, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, l1.Len())
+}
+
+func TestMultiLevelCacheDeleteByPrefix(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).Build()
+
+	assert.Nil(t, ch.MSet(context.TODO(), map[string]*EntityToCache{
+		"tenant:1:a": {Id: 1, ModelVersion: currentModelVersion},
+		"tenant:1:b": {Id: 2, ModelVersion: currentModelVersion},
+		"tenant:12":  {Id: 3, ModelVersion: currentModelVersion},
+	}))
+
+	removed, err := ch.DeleteByPrefix(context.TODO(), "tenant:1:")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, removed)
+
+	assert.Equal(t, 1, l1.Len())
+	assert.Equal(t, []string{"app:tenant:12"}, srv.Keys())
+
+	_, err = NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		Build().
+		DeleteByPrefix(context.TODO(), "tenant:1:")
+	assert.ErrorIs(t, err, ErrDeleteByPrefixNotSupported)
+}
+
+func TestMultiLevelCacheMGetWithMeta(t *testing.T) {
+	currentModelVersion := uint16(7)
+	l1 := NewMapCache[EntityToCache, int]()
+	l2 := NewMapCache[EntityToCache, int]()
+
+	keys := []*Key[int]{
+		{Key: "key1", OriginalValue: 1},
+		{Key: "key2", OriginalValue: 2},
+		{Key: "key3", OriginalValue: 3},
+		{Key: "key4", OriginalValue: 4},
+		{Key: "key5", OriginalValue: 5},
+		{Key: "key6", OriginalValue: 6},
+	}
+
+	assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+		keys[0].Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		keys[1].Key: {Id: 2, ModelVersion: currentModelVersion},
+		keys[2].Key: {Id: 3, ModelVersion: currentModelVersion},
+	}, time.Hour))
+	assert.Nil(t, l2.SetTombstones(context.TODO(), []string{keys[3].Key}, currentModelVersion, time.Hour))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+		WithSyncWriteback(true).
+		Build()
+
+	fn := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		assert.Len(t, keys, 2)
+
+		return map[*Key[int]]*EntityToCache{
+			keys[0]: {Id: 5, ModelVersion: currentModelVersion},
+		}, nil
+	}
+
+	results, meta, err := ch.MGetWithMeta(context.TODO(), append(keys, keys[0]), fn)
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+	assert.Equal(t, []int{1, 3}, meta.ProviderHits)
+	assert.Equal(t, 1, meta.FromSource)
+	assert.ElementsMatch(t, []*Key[int]{keys[3], keys[5]}, meta.NotFound)
+
+	_, meta, err = ch.MGetWithMeta(context.TODO(), keys[:3], fn)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 0}, meta.ProviderHits) // backfilled by previous call
+	assert.Equal(t, 0, meta.FromSource)
+	assert.Empty(t, meta.NotFound)
+}
+
+func TestMultiLevelCacheTTL(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	now := time.Now()
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+	lru.now = func() time.Time {
+		return now
+	}
+	redisProvider := NewRedisCache[EntityToCache, int](client)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Minute))
+	assert.Nil(t, redisProvider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, 10*time.Second))
+	now = now.Add(10 * time.Second)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, lru, redisProvider).Build()
+
+	ttl, err := ch.TTL(context.TODO(), key1)
+	assert.Nil(t, err)
+	assert.Equal(t, 50*time.Second, ttl)
+
+	ttl, err = ch.TTL(context.TODO(), key2)
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Second, ttl)
+
+	ttl, err = ch.TTL(context.TODO(), key3)
+	assert.Nil(t, err)
+	assert.Equal(t, TTLNotFound, ttl)
+
+	assert.Nil(t, client.Persist(context.TODO(), key2.Key).Err())
+	ttl, err = ch.TTL(context.TODO(), key2)
+	assert.Nil(t, err)
+	assert.Equal(t, TTLNoExpiry, ttl)
+
+	srv.FastForward(time.Hour)
+	now = now.Add(time.Hour)
+
+	ttl, err = ch.TTL(context.TODO(), key1)
+	assert.Nil(t, err)
+	assert.Equal(t, TTLNotFound, ttl)
+
+	mapCache := NewMapCache[EntityToCache, int]()
+	assert.Nil(t, mapCache.MSet(context.TODO(), map[string]*EntityToCache{key1.Key: {Id: 1}}, time.Minute))
+
+	ttl, err = NewCacheBuilder[EntityToCache, int](currentModelVersion, mapCache).Build().TTL(context.TODO(), key1)
+	assert.Nil(t, err)
+	assert.Equal(t, TTLNoExpiry, ttl)
+
+	_, err = NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		Build().TTL(context.TODO(), key1)
+	assert.ErrorIs(t, err, ErrTTLNotSupported)
+}
+
+func TestMultiLevelCacheBackfillsRecoveredProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	for name, parallel := range map[string]bool{"sequential": false, "parallel": true} {
+		t.Run(name, func(t *testing.T) {
+			srv, client := newTestRedis(t)
+			l1 := NewMapCache[EntityToCache, int]()
+			l2 := NewRedisCache[EntityToCache, int](client)
+
+			ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+				WithSyncWriteback(true).
+				WithParallelReads(parallel).
+				Build()
+
+			key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+			key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+			client.AddHook(&failingHook{failures: 1}) // read fails, write after source call succeeds
+
+			v, err := ch.Get(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+				return &EntityToCache{Id: 1, ModelVersion: currentModelVersion}, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, v.Id)
+			assert.True(t, srv.Exists(key1.Key))
+
+			client.AddHook(&failingHook{failures: 1})
+
+			results, err := ch.MGet(context.TODO(), []*Key[int]{key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+				return map[*Key[int]]*EntityToCache{key2: {Id: 2, ModelVersion: currentModelVersion}}, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, results[key2].Id)
+			assert.True(t, srv.Exists(key2.Key))
+		})
+	}
+}
+
+func TestMultiLevelCacheSkipProviders(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	for name, parallel := range map[string]bool{"sequential": false, "parallel": true} {
+		t.Run(name, func(t *testing.T) {
+			l1 := NewMapCache[EntityToCache, int]()
+			l2 := NewMapCache[EntityToCache, int]()
+
+			key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+			key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+			assert.Nil(t, l1.MSet(context.TODO(), map[string]*EntityToCache{
+				key1.Key: {Id: 1, Value: "stale", ModelVersion: currentModelVersion},
+				key2.Key: {Id: 2, Value: "stale", ModelVersion: currentModelVersion},
+			}, time.Hour))
+			assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+				key1.Key: {Id: 1, Value: "fresh", ModelVersion: currentModelVersion},
+				key2.Key: {Id: 2, Value: "fresh", ModelVersion: currentModelVersion},
+			}, time.Hour))
+
+			ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).
+				WithSyncWriteback(true).
+				WithParallelReads(parallel).
+				Build()
+
+			if !parallel { // parallel read may be won by either provider
+				v, err := ch.Get(context.TODO(), key1, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, "stale", v.Value)
+			}
+
+			v, result, err := ch.GetWithMeta(context.TODO(), key1, nil, WithSkipProviders(0))
+			assert.Nil(t, err)
+			assert.Equal(t, "fresh", v.Value)
+			assert.Equal(t, 1, result.ProviderIndex)
+
+			v, err = l1.Get(context.TODO(), key1, currentModelVersion)
+			assert.Nil(t, err)
+			assert.Equal(t, "fresh", v.Value) // backfilled
+
+			results, err := ch.MGet(context.TODO(), []*Key[int]{key2}, nil, WithSkipProviders(0))
+			assert.Nil(t, err)
+			assert.Equal(t, "fresh", results[key2].Value)
+
+			v, err = l1.Get(context.TODO(), key2, currentModelVersion)
+			assert.Nil(t, err)
+			assert.Equal(t, "fresh", v.Value)
+
+			v, err = ch.Get(context.TODO(), &Key[int]{Key: "key3", OriginalValue: 3}, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+				return &EntityToCache{Id: 3, Value: "source", ModelVersion: currentModelVersion}, nil
+			}, WithSkipProviders(0, 1))
+			assert.Nil(t, err)
+			assert.Equal(t, "source", v.Value)
+			assert.Equal(t, 3, l1.Len())
+			assert.Equal(t, 3, l2.Len())
+		})
+	}
+}
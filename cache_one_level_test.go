@@ -3,13 +3,19 @@ package cache
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type EntityToCache struct {
@@ -141,6 +147,7 @@ func TestOneLevelCacheMultiRecord(t *testing.T) {
 
 	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
 		WithTtl(ttl).
+		WithSyncWriteback(true).
 		Build()
 
 	called := false
@@ -162,7 +169,6 @@ func TestOneLevelCacheMultiRecord(t *testing.T) {
 		}, nil
 	})
 
-	time.Sleep(300 * time.Millisecond) // set to cache is async
 	assert.Nil(t, err)
 	assert.True(t, called)
 	mockCacheProvider.AssertExpectations(t)
@@ -217,7 +223,6 @@ func TestOneLevelCacheMultiRecordWithoutDataSource(t *testing.T) {
 		return nil, errors.New("should not be called")
 	})
 
-	time.Sleep(300 * time.Millisecond) // set to cache is async
 	assert.Nil(t, err)
 	assert.False(t, called)
 	mockCacheProvider.AssertExpectations(t)
@@ -264,6 +269,7 @@ func TestOneLevelCacheMultiRecordWitPartialDataSource(t *testing.T) {
 		}).Return(nil)
 
 	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithSyncWriteback(true).
 		Build()
 
 	called := false
@@ -282,7 +288,6 @@ func TestOneLevelCacheMultiRecordWitPartialDataSource(t *testing.T) {
 		}, nil
 	})
 
-	time.Sleep(300 * time.Millisecond) // set to cache is async
 	assert.Nil(t, err)
 	assert.True(t, called)
 	mockCacheProvider.AssertExpectations(t)
@@ -292,3 +297,1724 @@ func TestOneLevelCacheMultiRecordWitPartialDataSource(t *testing.T) {
 	assert.Equal(t, key.OriginalValue, result[key].Id)
 	assert.Equal(t, currentModelVersion, result[key].GetCacheModelVersion())
 }
+
+func TestOneLevelCacheSingleflight(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	mockCacheProvider.EXPECT().Get(context.TODO(), key, currentModelVersion).
+		Return(nil, nil)
+	mockCacheProvider.EXPECT().MSet(context.TODO(), mock.Anything, mock.Anything).
+		Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithSingleflight(true).
+		Build()
+
+	var calls int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			<-start
+
+			result, err := ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(200 * time.Millisecond)
+
+				return &EntityToCache{
+					Id:           key.OriginalValue,
+					Value:        "random_content",
+					ModelVersion: currentModelVersion,
+				}, nil
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "random_content", result.Value)
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestOneLevelCacheSingleflightCanceledCaller(t *testing.T) {
+	currentModelVersion := uint16(7)
+	key := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		WithSingleflight(true).
+		WithSourceTimeout(time.Minute).
+		Build()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	v, err := ch.Get(ctx, key, func(sourceCtx context.Context, key *Key[int]) (*EntityToCache, error) {
+		cancel() // caller which started the call goes away
+
+		_, hasDeadline := sourceCtx.Deadline()
+		assert.True(t, hasDeadline)
+
+		if err := sourceCtx.Err(); err != nil {
+			return nil, err
+		}
+
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+}
+
+func TestOneLevelCacheSingleNegativeCaching(t *testing.T) {
+	currentModelVersion := uint16(7)
+	negativeTtl := 10 * time.Second
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	mockCacheProvider.EXPECT().Get(context.TODO(), key, currentModelVersion).
+		Return(nil, nil).Once()
+	mockCacheProvider.EXPECT().SetTombstones(context.TODO(), []string{key.Key}, currentModelVersion, negativeTtl).
+		Return(nil).Once()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithNegativeCaching(negativeTtl).
+		Build()
+
+	calls := 0
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		calls++
+
+		return nil, nil
+	}
+
+	result, err := ch.Get(context.TODO(), key, fn)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+
+	mockCacheProvider.EXPECT().Get(context.TODO(), key, currentModelVersion).
+		Return(nil, ErrTombstone).Once()
+
+	result, err = ch.Get(context.TODO(), key, fn)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+
+	assert.Equal(t, 1, calls)
+	mockCacheProvider.AssertExpectations(t)
+}
+
+func TestOneLevelCacheStats(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	randId2 := randId + 555
+	key2 := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId2),
+		OriginalValue: randId2,
+	}
+
+	keysArr := []*Key[int]{key, key2}
+
+	mockCacheProvider.EXPECT().MGet(context.TODO(), keysArr, currentModelVersion).
+		Return(map[*Key[int]]*EntityToCache{
+			key2: {Id: key2.OriginalValue, ModelVersion: currentModelVersion},
+		}, []*Key[int]{key}, nil)
+	mockCacheProvider.EXPECT().MSet(context.TODO(), mock.Anything, mock.Anything).
+		Return(nil)
+	mockCacheProvider.EXPECT().Get(context.TODO(), key, currentModelVersion).
+		Return(nil, nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithSyncWriteback(true).
+		Build()
+
+	_, err := ch.MGet(context.TODO(), keysArr, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key: {Id: key.OriginalValue, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	_, err = ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return nil, errors.New("source is down")
+	})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, CacheStats{
+		Hits:         1,
+		Misses:       2,
+		SourceCalls:  2,
+		SourceErrors: 1,
+	}, ch.Stats())
+}
+
+func TestOneLevelCacheSingleTracing(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	mockCacheProvider.EXPECT().Get(mock.Anything, key, currentModelVersion).
+		Return(nil, nil)
+	mockCacheProvider.EXPECT().MSet(mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithTracer(tracerProvider.Tracer("test")).
+		Build()
+
+	_, err := ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{
+			Id:           key.OriginalValue,
+			ModelVersion: currentModelVersion,
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	assert.Equal(t, 3, len(spans))
+	assert.Equal(t, spans["cache.Get"].SpanContext().SpanID(), spans["cache.source"].Parent().SpanID())
+	assert.Equal(t, spans["cache.Get"].SpanContext().SpanID(), spans["cache.provider.Get"].Parent().SpanID())
+	assert.Contains(t, spans["cache.provider.Get"].Attributes(), attribute.String("cache.provider", "cache.mockProvider"))
+	assert.Contains(t, spans["cache.Get"].Attributes(), attribute.Bool("cache.source_invoked", true))
+}
+
+func TestOneLevelCacheMultiRecordSyncWriteback(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	keysArr := []*Key[int]{key}
+
+	mockCacheProvider.EXPECT().MGet(context.TODO(), keysArr, currentModelVersion).
+		Return(nil, keysArr, nil)
+
+	mockCacheProvider.EXPECT().MSet(context.TODO(), mock.Anything, mock.Anything).
+		Run(func(ctx context.Context, values map[string]*EntityToCache, ttl time.Duration) {
+			assert.Equal(t, 1, len(values))
+			assert.Equal(t, "random_content", values[key.Key].Value)
+		}).Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithSyncWriteback(true).
+		Build()
+
+	result, err := ch.MGet(context.TODO(), keysArr, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key: {
+				Id:           key.OriginalValue,
+				Value:        "random_content",
+				ModelVersion: currentModelVersion,
+			},
+		}, nil
+	})
+
+	assert.Nil(t, err)
+	mockCacheProvider.AssertExpectations(t)
+	assert.Equal(t, "random_content", result[key].Value)
+}
+
+func TestOneLevelCacheMultiRecordWritebackErrorHandler(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	randId := rand.Int()
+	key := &Key[int]{
+		Key:           fmt.Sprintf("totaly_random_prefix_with_key_%v", randId),
+		OriginalValue: randId,
+	}
+
+	keysArr := []*Key[int]{key}
+
+	mockCacheProvider.EXPECT().MGet(context.TODO(), keysArr, currentModelVersion).
+		Return(nil, keysArr, nil)
+	mockCacheProvider.EXPECT().MSet(context.TODO(), mock.Anything, mock.Anything).
+		Return(errors.New("redis is down"))
+
+	errCh := make(chan error, 1)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithWritebackErrorHandler(func(err error) {
+			errCh <- err
+		}).
+		Build()
+
+	_, err := ch.MGet(context.TODO(), keysArr, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key: {Id: key.OriginalValue, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	select {
+	case writebackErr := <-errCh:
+		assert.ErrorContains(t, writebackErr, "redis is down")
+		assert.ErrorContains(t, writebackErr, "cache.mockProvider")
+	case <-time.After(time.Second):
+		assert.Fail(t, "writeback error handler was not called")
+	}
+}
+
+func TestOneLevelCacheSet(t *testing.T) {
+	currentModelVersion := uint16(7)
+	ttl := 3 * time.Minute
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	value := &EntityToCache{Id: 1, ModelVersion: currentModelVersion}
+
+	mockCacheProvider.EXPECT().MSet(context.TODO(), map[string]*EntityToCache{key.Key: value}, ttl).
+		Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithTtl(ttl).
+		Build()
+
+	assert.Nil(t, ch.Set(context.TODO(), key, value))
+	mockCacheProvider.AssertExpectations(t)
+}
+
+func TestOneLevelCacheRefreshSkipsProviderReads(t *testing.T) {
+	currentModelVersion := uint16(7)
+	ttl := 3 * time.Minute
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	fresh := &EntityToCache{Id: 1, Value: "fresh", ModelVersion: currentModelVersion}
+
+	mockCacheProvider.EXPECT().MSet(context.TODO(), map[string]*EntityToCache{key.Key: fresh}, ttl).
+		Return(nil)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithTtl(ttl).
+		Build()
+
+	v, err := ch.Refresh(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return fresh, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, fresh, v)
+	assert.Equal(t, uint64(1), ch.Stats().SourceCalls)
+	mockCacheProvider.AssertExpectations(t)
+}
+
+func TestOneLevelCacheMRefresh(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, Value: "stale", ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, Value: "stale", ModelVersion: currentModelVersion},
+	}, 0))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		Build()
+
+	resp, err := ch.MRefresh(context.TODO(), []*Key[int]{key1, key2},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			return map[*Key[int]]*EntityToCache{
+				key1: {Id: 1, Value: "fresh", ModelVersion: currentModelVersion},
+			}, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp))
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing)
+	assert.Equal(t, "fresh", found[key1].Value)
+}
+
+type recordingLogger struct {
+	errs   []error
+	msgs   []string
+	levels []slog.Level
+}
+
+func (l *recordingLogger) Warn(err error, msg string, _ ...slog.Attr) {
+	l.record(slog.LevelWarn, err, msg)
+}
+
+func (l *recordingLogger) Error(err error, msg string, _ ...slog.Attr) {
+	l.record(slog.LevelError, err, msg)
+}
+
+func (l *recordingLogger) record(level slog.Level, err error, msg string) {
+	l.errs = append(l.errs, err)
+	l.msgs = append(l.msgs, msg)
+	l.levels = append(l.levels, level)
+}
+
+func TestOneLevelCacheLogger(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	mockCacheProvider := newMockProvider[EntityToCache, int](t)
+	logger := &recordingLogger{}
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	value := &EntityToCache{Id: 1, ModelVersion: currentModelVersion}
+
+	mockCacheProvider.EXPECT().Get(context.TODO(), key, currentModelVersion).
+		Return(nil, errors.New("provider is down"))
+	mockCacheProvider.EXPECT().MSet(context.TODO(), map[string]*EntityToCache{key.Key: value}, DefaultTtl).
+		Return(nil) // backfill once provider recovers
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, mockCacheProvider).
+		WithLogger(logger).
+		Build()
+
+	v, err := ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return value, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, value, v)
+	assert.Equal(t, []string{"can not get from provider"}, logger.msgs)
+	assert.ErrorContains(t, logger.errs[0], "provider is down")
+}
+
+func TestOneLevelCacheCorruptEntryLogger(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	providerLogger := &recordingLogger{}
+	cacheLogger := &recordingLogger{}
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, srv.Set(key.Key, "not msgpack"))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion,
+		NewRedisCache[EntityToCache, int](client, WithLogger(providerLogger))).
+		WithLogger(cacheLogger).
+		WithSyncWriteback(true).
+		Build()
+
+	v, err := ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+	assert.Empty(t, cacheLogger.msgs) // corruption is not a provider failure
+	assert.Equal(t, []slog.Level{slog.LevelWarn}, providerLogger.levels)
+	assert.Equal(t, []string{"can not decode cached value"}, providerLogger.msgs)
+}
+
+func TestOneLevelCacheCircuitOpenWriteback(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	failing := &failingHook{failures: 1}
+	commands := &commandsHook{}
+	client.AddHook(commands)
+	client.AddHook(failing)
+
+	logger := &recordingLogger{}
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion,
+		NewRedisCache[EntityToCache, int](client, WithCircuitBreaker(1, time.Minute), WithLogger(logger))).
+		WithLogger(logger).
+		WithNegativeCaching(time.Minute).
+		WithSyncWriteback(true).
+		Build()
+
+	_, err := ch.Get(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: 1, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"can not get from provider"}, logger.msgs) // failure which opens breaker
+
+	sent := len(commands.args)
+
+	_, err = ch.Get(context.TODO(), key2, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return nil, nil
+	})
+	assert.Nil(t, err)
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{key1: {Id: 1, ModelVersion: currentModelVersion}}, nil
+	})
+	assert.Nil(t, err)
+
+	assert.Len(t, commands.args, sent) // no writes are attempted while breaker is open
+	assert.Len(t, logger.msgs, 1)
+}
+
+func TestOneLevelCacheDefaultLoader(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		WithSyncWriteback(true).
+		WithDefaultLoader(
+			func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+				return &EntityToCache{Id: key.OriginalValue, Value: "default", ModelVersion: currentModelVersion}, nil
+			},
+			func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+				res := map[*Key[int]]*EntityToCache{}
+				for _, k := range keys {
+					res[k] = &EntityToCache{Id: k.OriginalValue, Value: "default", ModelVersion: currentModelVersion}
+				}
+				return res, nil
+			}).
+		Build()
+
+	v, err := ch.Get(context.TODO(), key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "default", v.Value)
+
+	resp, err := ch.MGet(context.TODO(), []*Key[int]{key2}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "default", resp[key2].Value)
+
+	v, err = ch.Refresh(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, Value: "explicit", ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "explicit", v.Value)
+}
+
+func TestOneLevelCacheMGetWithMissingNegativeCaching(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithNegativeCaching(time.Minute).
+		WithSyncWriteback(true).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	sourceCalls := 0
+	source := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		sourceCalls++
+		return map[*Key[int]]*EntityToCache{
+			key1: {Id: 1, ModelVersion: currentModelVersion},
+		}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, notFound, err := ch.MGetWithMissing(context.TODO(), []*Key[int]{key1, key2}, source)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(resp))
+		assert.Equal(t, []*Key[int]{key2}, notFound)
+	}
+
+	assert.Equal(t, 1, sourceCalls)
+}
+
+func TestOneLevelCacheSourceTimeout(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		WithSourceTimeout(20 * time.Millisecond).
+		Build()
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	start := time.Now()
+
+	_, err := ch.Get(context.TODO(), key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return &EntityToCache{Id: 1, ModelVersion: currentModelVersion}, nil
+		}
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStrictVersionOnSet(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	stale := &EntityToCache{Id: 1, ModelVersion: currentModelVersion - 1}
+
+	lenient := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build()
+	assert.Nil(t, lenient.Set(context.TODO(), key, stale))
+	assert.Equal(t, 1, provider.Len())
+
+	assert.Nil(t, provider.Clear(context.TODO()))
+
+	strict := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithStrictVersionOnSet(true).
+		Build()
+
+	err := strict.Set(context.TODO(), key, stale)
+	assert.ErrorIs(t, err, ErrModelVersionMismatch)
+	assert.Contains(t, err.Error(), "key key")
+	assert.Equal(t, 0, provider.Len())
+
+	assert.Nil(t, strict.Set(context.TODO(), key, &EntityToCache{Id: 1, ModelVersion: currentModelVersion}))
+	assert.Equal(t, 1, provider.Len())
+}
+
+func TestOneLevelCacheSourceErrors(t *testing.T) {
+	currentModelVersion := uint16(7)
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	sourceErr := errors.New("db is down")
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).Build()
+
+	_, err := ch.Get(context.TODO(), key, nil)
+	assert.ErrorIs(t, err, ErrNoSourceFn)
+
+	var target *SourceError
+	assert.False(t, errors.As(err, &target))
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return nil, sourceErr
+	})
+	assert.ErrorIs(t, err, sourceErr)
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, sourceErr, target.Err)
+	assert.Contains(t, err.Error(), "can not get from source")
+}
+
+func TestOneLevelCacheMGetPartialResults(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	sourceErr := errors.New("db is down")
+
+	provider := NewMapCache[EntityToCache, int]()
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	fn := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return nil, sourceErr
+	}
+
+	strict := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build()
+
+	resp, err := strict.MGet(context.TODO(), []*Key[int]{key1, key2}, fn)
+	assert.ErrorIs(t, err, sourceErr)
+	assert.Nil(t, resp)
+
+	partial := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithPartialResults(true).
+		Build()
+
+	resp, notFound, err := partial.MGetWithMissing(context.TODO(), []*Key[int]{key1, key2}, fn)
+	assert.ErrorIs(t, err, sourceErr)
+	assert.Equal(t, 1, len(resp))
+	assert.Equal(t, 1, resp[key1].Id)
+	assert.Empty(t, notFound)
+}
+
+func TestOneLevelCacheZeroTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewRedisCache[EntityToCache, int](client)).
+		WithTtl(0).
+		Build()
+
+	assert.Nil(t, ch.Set(context.TODO(), key, &EntityToCache{Id: 1, ModelVersion: currentModelVersion}))
+	assert.True(t, srv.Exists(key.Key))
+	assert.Equal(t, time.Duration(0), srv.TTL(key.Key))
+}
+
+func TestOneLevelCacheNegativeTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	logger := &recordingLogger{}
+
+	b := NewCacheBuilder[EntityToCache, int](currentModelVersion).
+		WithTtl(-time.Second).
+		WithLogger(logger) // logger set after WithTtl still gets the warning
+
+	assert.Equal(t, DefaultTtl, b.ttl)
+	assert.Empty(t, logger.msgs)
+
+	b.Build()
+	assert.Equal(t, []string{"ttl is replaced by default one"}, logger.msgs)
+
+	b.WithTtl(time.Second).Build()
+	assert.Len(t, logger.msgs, 1)
+}
+
+func TestOneLevelCacheCallTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewRedisCache[EntityToCache, int](client)).
+		WithTtl(time.Hour).
+		WithSyncWriteback(true).
+		Build()
+
+	_, err := ch.Get(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	}, WithCallTtl(30*time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, srv.TTL(key1.Key))
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key2: {Id: key2.OriginalValue, ModelVersion: currentModelVersion},
+		}, nil
+	}, WithCallTtl(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, srv.TTL(key2.Key))
+
+	assert.Nil(t, ch.Delete(context.TODO(), key1))
+
+	_, err = ch.Get(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: currentModelVersion}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, srv.TTL(key1.Key))
+}
+
+func TestOneLevelCacheSetModelVersion(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	sourceCalls := 0
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).Build()
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		sourceCalls++
+
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: ch.ModelVersion()}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := ch.Get(context.TODO(), key, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, currentModelVersion, v.ModelVersion)
+	}
+	assert.Equal(t, 1, sourceCalls)
+
+	ch.SetModelVersion(currentModelVersion + 1)
+	assert.Equal(t, currentModelVersion+1, ch.ModelVersion())
+
+	v, err := ch.Get(context.TODO(), key, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, currentModelVersion+1, v.ModelVersion)
+	assert.Equal(t, 2, sourceCalls)
+}
+
+func TestOneLevelCacheMGetWithErrors(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+	keyErr := errors.New("row is locked")
+
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithSyncWriteback(true).
+		WithNegativeCaching(time.Minute).
+		Build()
+
+	resp, keyErrs, err := ch.MGetWithErrors(context.TODO(), []*Key[int]{key1, key2, key3},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, map[*Key[int]]error, error) {
+			return map[*Key[int]]*EntityToCache{
+				key1: {Id: 1, ModelVersion: currentModelVersion},
+			}, map[*Key[int]]error{
+				key2: keyErr,
+			}, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp))
+	assert.Equal(t, 1, resp[key1].Id)
+	assert.Equal(t, map[*Key[int]]error{key2: keyErr}, keyErrs)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, found[key1].Id)
+	assert.Nil(t, found[key3]) // tombstone
+	assert.Equal(t, []*Key[int]{key2}, missing)
+}
+
+func TestOneLevelCacheClose(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	provider := NewMapCache[EntityToCache, int]()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build()
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{key}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key: {Id: key.OriginalValue, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Close(context.TODO()))
+	assert.Equal(t, 1, provider.Len())
+
+	_, err = ch.Get(context.TODO(), key, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key}, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	assert.ErrorIs(t, ch.Set(context.TODO(), key, nil), ErrClosed)
+}
+
+func TestOneLevelCacheNewKey(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).Build()
+	assert.Equal(t, &Key[int]{Key: "42", OriginalValue: 42}, ch.NewKey(42))
+
+	ch = NewCacheBuilder[EntityToCache, int](currentModelVersion, NewMapCache[EntityToCache, int]()).
+		WithKeyFunc(func(original int) string {
+			return fmt.Sprintf("entity:%v", original)
+		}).
+		Build()
+	assert.Equal(t, &Key[int]{Key: "entity:42", OriginalValue: 42}, ch.NewKey(42))
+}
+
+func TestOneLevelCacheDoNotCache(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	provider := NewMapCache[EntityToCache, int]()
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithSyncWriteback(true).
+		WithNegativeCaching(time.Minute).
+		WithSingleflight(true).
+		Build()
+
+	v, err := ch.Get(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return &EntityToCache{Id: -1, ModelVersion: currentModelVersion}, errors.Wrap(ErrDoNotCache, "serving default")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, -1, v.Id)
+
+	resp, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			key1: {Id: -1, ModelVersion: currentModelVersion},
+		}, ErrDoNotCache
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp))
+	assert.Equal(t, -1, resp[key1].Id)
+
+	assert.Equal(t, 0, provider.Len())
+}
+
+func TestOneLevelCacheAcceptedVersions(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	provider := NewMapCache[EntityToCache, int](WithKeepStaleVersions(true))
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, Value: "old", ModelVersion: currentModelVersion - 1},
+		key2.Key: {Id: 2, Value: "old", ModelVersion: currentModelVersion - 1},
+	}, time.Hour))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).
+		WithSyncWriteback(true).
+		WithAcceptedVersions(currentModelVersion - 1).
+		WithVersionUpgrader(func(item *EntityToCache, version uint16) (*EntityToCache, error) {
+			return &EntityToCache{Id: item.Id, Value: "upgraded", ModelVersion: currentModelVersion}, nil
+		}).
+		Build()
+
+	noSource := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return nil, errors.New("source must not be called")
+	}
+
+	v, meta, err := ch.GetWithMeta(context.TODO(), key1, noSource)
+	assert.Nil(t, err)
+	assert.Equal(t, "upgraded", v.Value)
+	assert.True(t, meta.Hit)
+	assert.Equal(t, 0, meta.ProviderIndex)
+
+	resp, err := ch.MGet(context.TODO(), []*Key[int]{key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return nil, errors.New("source must not be called")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "upgraded", resp[key2].Value)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, "upgraded", found[key1].Value)
+	assert.Equal(t, "upgraded", found[key2].Value)
+}
+
+type ttlEntity struct {
+	Id           int
+	Ttl          time.Duration
+	ModelVersion uint16
+}
+
+func (e ttlEntity) GetCacheModelVersion() uint16 {
+	return e.ModelVersion
+}
+
+func (e ttlEntity) GetCacheTTL() time.Duration {
+	return e.Ttl
+}
+
+func TestOneLevelCacheEntityTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	ch := NewCacheBuilder[ttlEntity, int](currentModelVersion, NewRedisCache[ttlEntity, int](client)).
+		WithTtl(time.Hour).
+		WithSyncWriteback(true).
+		Build()
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*ttlEntity, error) {
+		return map[*Key[int]]*ttlEntity{
+			key1: {Id: 1, Ttl: time.Minute, ModelVersion: currentModelVersion},
+			key2: {Id: 2, Ttl: 10 * time.Second, ModelVersion: currentModelVersion},
+			key3: {Id: 3, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, time.Minute, srv.TTL(key1.Key))
+	assert.Equal(t, 10*time.Second, srv.TTL(key2.Key))
+	assert.Equal(t, time.Hour, srv.TTL(key3.Key))
+}
+
+type pointerTtlEntity struct {
+	Id           int
+	Ttl          time.Duration
+	ModelVersion uint16
+}
+
+func (e pointerTtlEntity) GetCacheModelVersion() uint16 {
+	return e.ModelVersion
+}
+
+func (e *pointerTtlEntity) GetCacheTTL() time.Duration {
+	return e.Ttl
+}
+
+func TestOneLevelCacheEntityTtlPointerReceiver(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[pointerTtlEntity, int](currentModelVersion, NewRedisCache[pointerTtlEntity, int](client)).
+		WithTtl(time.Hour).
+		Build()
+
+	assert.Nil(t, ch.MSet(context.TODO(), map[string]*pointerTtlEntity{
+		key1.Key: {Id: 1, Ttl: time.Minute, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}))
+
+	assert.Equal(t, time.Minute, srv.TTL(key1.Key))
+	assert.Equal(t, time.Hour, srv.TTL(key2.Key))
+}
+
+func TestOneLevelCacheEntityTtlWithProviderTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+	key4 := &Key[int]{Key: "key4", OriginalValue: 4}
+
+	ch := NewCacheBuilder[ttlEntity, int](currentModelVersion,
+		WithProviderTtl(NewRedisCache[ttlEntity, int](client), 30*time.Second)).
+		WithTtl(time.Hour).
+		WithSyncWriteback(true).
+		Build()
+
+	fn := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*ttlEntity, error) {
+		results := map[*Key[int]]*ttlEntity{}
+		for _, k := range keys {
+			results[k] = &ttlEntity{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+			if k == key1 || k == key4 {
+				results[k].Ttl = time.Minute
+			}
+		}
+
+		return results, nil
+	}
+
+	_, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, fn)
+	assert.Nil(t, err)
+
+	_, err = ch.MGet(context.TODO(), []*Key[int]{key3, key4}, fn, WithCallTtl(10*time.Second))
+	assert.Nil(t, err)
+
+	assert.Equal(t, time.Minute, srv.TTL(key1.Key))    // entity ttl wins over provider ttl
+	assert.Equal(t, 30*time.Second, srv.TTL(key2.Key)) // provider ttl wins over builder ttl
+	assert.Equal(t, 10*time.Second, srv.TTL(key3.Key)) // call ttl wins over provider ttl
+	assert.Equal(t, time.Minute, srv.TTL(key4.Key))    // entity ttl wins over call ttl
+}
+
+func TestOneLevelCacheMGetSlice(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	keys := []*Key[int]{
+		{Key: "key1", OriginalValue: 1},
+		{Key: "key2", OriginalValue: 2},
+		{Key: "key3", OriginalValue: 3},
+		{Key: "key4", OriginalValue: 4},
+	}
+	provider := NewMapCache[EntityToCache, int]()
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		keys[2].Key: {Id: 3, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build()
+
+	values, err := ch.MGetSlice(context.TODO(), keys, func(ctx context.Context, missing []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{
+			keys[1]: {Id: 2, ModelVersion: currentModelVersion},
+		}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(values))
+	assert.Nil(t, values[0])
+	assert.Equal(t, 2, values[1].Id)
+	assert.Equal(t, 3, values[2].Id)
+	assert.Nil(t, values[3])
+}
+
+func TestOneLevelCacheMGetDuplicateKeys(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	commands := &commandsHook{}
+	client.AddHook(commands)
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewRedisCache[EntityToCache, int](client)).
+		WithSyncWriteback(true).
+		WithNegativeCaching(time.Minute).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key1Dup := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key2Dup := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	var sourceKeys [][]*Key[int]
+
+	resp, missing, err := ch.MGetWithMissing(context.TODO(), []*Key[int]{key1, key2, key1Dup, key2Dup, key1},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			sourceKeys = append(sourceKeys, keys)
+
+			return map[*Key[int]]*EntityToCache{
+				key1: {Id: 1, ModelVersion: currentModelVersion},
+			}, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]*Key[int]{{key1, key2}}, sourceKeys)
+	assert.Equal(t, 2, len(resp))
+	assert.Same(t, resp[key1], resp[key1Dup])
+	assert.ElementsMatch(t, []*Key[int]{key2, key2Dup}, missing)
+	assert.Equal(t, [][]interface{}{
+		{"mget", "key1", "key2"},
+		{"set", "key2", encodeTombstone(currentModelVersion), "ex", int64(60)},
+		{"set", "key1", commands.args[2][2], "ex", int64(300)},
+	}, commands.args)
+}
+
+func TestOneLevelCacheGetWithTTLFromSource(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewRedisCache[EntityToCache, int](client)).
+		WithTtl(time.Hour).
+		WithSyncWriteback(true).
+		WithSingleflight(true).
+		Build()
+
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, time.Duration, error) {
+		if key.OriginalValue == 1 {
+			return &EntityToCache{Id: 1, ModelVersion: currentModelVersion}, 30 * time.Second, nil
+		}
+
+		return &EntityToCache{Id: 2, ModelVersion: currentModelVersion}, 0, nil
+	}
+
+	v, err := ch.GetWithTTLFromSource(context.TODO(), key1, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	v, err = ch.GetWithTTLFromSource(context.TODO(), key2, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+
+	assert.Equal(t, 30*time.Second, srv.TTL(key1.Key))
+	assert.Equal(t, time.Hour, srv.TTL(key2.Key))
+}
+
+func TestOneLevelCacheReconfigureConcurrently(t *testing.T) {
+	builder := NewCacheBuilder[EntityToCache, int](1, NewMapCache[EntityToCache, int]()).
+		WithNegativeCaching(time.Minute)
+	ch := builder.Build()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				key := &Key[int]{Key: fmt.Sprint(i), OriginalValue: i}
+
+				_, _ = ch.Get(ctx, key, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+					return &EntityToCache{Id: key.OriginalValue, ModelVersion: ch.ModelVersion()}, nil
+				})
+				_, _ = ch.MGet(ctx, []*Key[int]{key}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+					return nil, nil
+				})
+				_ = ch.MSet(ctx, map[string]*EntityToCache{key.Key: {Id: i, ModelVersion: ch.ModelVersion()}})
+			}
+		}(i)
+	}
+
+	for i := 0; i < 100; i++ {
+		ch.SetModelVersion(uint16(i % 3))
+		ch.SetTtl(time.Duration(i) * time.Second)
+		ch.SetNegativeTtl(time.Duration(i%2) * time.Second)
+		builder.WithTtl(time.Duration(i) * time.Second)
+	}
+
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, uint16(99%3), ch.ModelVersion())
+	assert.Equal(t, 99*time.Second, ch.Ttl())
+	assert.Equal(t, time.Second, ch.NegativeTtl())
+}
+
+func TestOneLevelCacheRegisterRefresh(t *testing.T) {
+	currentModelVersion := uint16(7)
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	var calls atomic.Int32
+	refreshed := make(chan struct{})
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		select {
+		case refreshed <- struct{}{}:
+		case <-ctx.Done(): // unregistered refresh is never observed
+			return nil, ctx.Err()
+		}
+
+		return &EntityToCache{Id: int(calls.Add(1)), ModelVersion: currentModelVersion}, nil
+	}
+	waitRefresh := func() {
+		select {
+		case <-refreshed:
+		case <-time.After(time.Second):
+			t.Fatalf("key is not refreshed")
+		}
+	}
+
+	assert.Nil(t, ch.RegisterRefresh(key1, fn, 10*time.Millisecond))
+	assert.Nil(t, ch.RegisterRefresh(key1, fn, 10*time.Millisecond)) // no second goroutine
+
+	for i := 0; i < 5; i++ {
+		waitRefresh()
+	}
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.NotNil(t, v)
+
+	ch.UnregisterRefresh(key1)
+	assert.Never(t, func() bool {
+		select {
+		case <-refreshed:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, time.Millisecond)
+
+	assert.Nil(t, ch.RegisterRefresh(key1, fn, time.Hour))
+	waitRefresh()
+
+	assert.Nil(t, ch.Close(context.TODO()))
+	assert.ErrorIs(t, ch.RegisterRefresh(key1, fn, time.Hour), ErrClosed)
+	assert.Error(t, NewCacheBuilder[EntityToCache, int](currentModelVersion, provider).Build().RegisterRefresh(key1, fn, 0))
+}
+
+func TestOneLevelCacheRegisterRefreshDuringClose(t *testing.T) {
+	ch := NewCacheBuilder[EntityToCache, int](7, NewMapCache[EntityToCache, int]()).Build()
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	// Close stopped refreshers after RegisterRefresh passed closed check
+	ch.refreshers.close()
+
+	assert.ErrorIs(t, ch.RegisterRefresh(key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		return nil, nil
+	}, time.Hour), ErrClosed)
+	assert.Empty(t, ch.refreshers.cancels)
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		v := jitter(time.Second)
+		assert.GreaterOrEqual(t, v, 900*time.Millisecond)
+		assert.LessOrEqual(t, v, 1100*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Nanosecond, jitter(time.Nanosecond))
+}
+
+func TestOneLevelCacheUnversioned(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	providers := map[string]Provider[EntityToCache, int]{
+		"map":   NewMapCache[EntityToCache, int](),
+		"lru":   NewLRUCache[EntityToCache, int](10, time.Hour),
+		"redis": NewRedisCache[EntityToCache, int](client),
+	}
+
+	for name, provider := range providers {
+		t.Run(name, func(t *testing.T) {
+			key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+			key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+			key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+			assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+				key1.Key: {Id: 1, ModelVersion: 3},
+				key2.Key: {Id: 2},
+			}, time.Hour))
+			assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, 5, time.Hour))
+
+			ch := NewCacheBuilder[EntityToCache, int](Unversioned, provider).
+				WithStrictVersionOnSet(true).
+				Build()
+
+			results, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+				t.Fatalf("source must not be called for %v", keys)
+				return nil, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, results[key1].Id)
+			assert.Equal(t, 2, results[key2].Id)
+			assert.Nil(t, results[key3])
+
+			assert.Nil(t, ch.MSet(context.TODO(), map[string]*EntityToCache{key1.Key: {Id: 10, ModelVersion: 4}}))
+
+			v, err := ch.Get(context.TODO(), key1, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, 10, v.Id)
+		})
+	}
+}
+
+func TestOneLevelCacheContextLoader(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	single := func(source string) GetSingleFromSourceFn[EntityToCache, int] {
+		return func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+			return &EntityToCache{Id: key.OriginalValue, Value: source, ModelVersion: currentModelVersion}, nil
+		}
+	}
+	multi := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		results := map[*Key[int]]*EntityToCache{}
+		for _, k := range keys {
+			results[k] = &EntityToCache{Id: k.OriginalValue, Value: "context", ModelVersion: currentModelVersion}
+		}
+
+		return results, nil
+	}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).Build()
+
+	_, err := ch.Get(context.TODO(), key1, nil)
+	assert.ErrorIs(t, err, ErrNoSourceFn)
+
+	ctx := WithLoader(context.TODO(), single("context"), multi)
+
+	v, err := ch.Get(ctx, key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "context", v.Value)
+
+	v, err = ch.Get(ctx, key1, single("explicit"))
+	assert.Nil(t, err)
+	assert.Equal(t, "explicit", v.Value)
+
+	results, err := ch.MGet(ctx, []*Key[int]{key1, key2}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "context", results[key2].Value)
+
+	// loaders of other entity type are not used
+	_, err = ch.Get(WithLoader[EntityToCache, string](context.TODO(), nil, nil), key1, nil)
+	assert.ErrorIs(t, err, ErrNoSourceFn)
+
+	ch = NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithDefaultLoader(single("default"), nil).
+		Build()
+
+	v, err = ch.Get(ctx, key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "context", v.Value)
+
+	v, err = ch.Get(WithLoader[EntityToCache, int](context.TODO(), nil, multi), key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "default", v.Value)
+}
+
+func TestOneLevelCacheBatchCoalescing(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	keys := make([]*Key[int], 5)
+	for i := range keys {
+		keys[i] = &Key[int]{Key: fmt.Sprint(i), OriginalValue: i}
+	}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithBatchCoalescing(true).
+		Build()
+
+	release := make(chan struct{})
+	var mut sync.Mutex
+	var calls [][]int
+
+	fn := func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		mut.Lock()
+		var ids []int
+		for _, k := range keys {
+			ids = append(ids, k.OriginalValue)
+		}
+		calls = append(calls, ids)
+		mut.Unlock()
+
+		if keys[0].OriginalValue == 1 {
+			<-release
+		}
+
+		results := map[*Key[int]]*EntityToCache{}
+		for _, k := range keys {
+			if k.OriginalValue != 3 { // not found in source
+				results[k] = &EntityToCache{Id: k.OriginalValue, ModelVersion: currentModelVersion}
+			}
+		}
+
+		return results, nil
+	}
+
+	callCount := func() int {
+		mut.Lock()
+		defer mut.Unlock()
+
+		return len(calls)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]map[*Key[int]]*EntityToCache, 3)
+	batches := [][]*Key[int]{
+		{keys[1], keys[2], keys[3]},
+		{keys[2], keys[3], keys[4]},
+		{keys[3], keys[2], keys[0]}, // keys[0] is loaded once keys[3] and keys[2] are awaited
+	}
+
+	for i, batch := range batches {
+		wg.Add(1)
+
+		go func(i int, batch []*Key[int]) {
+			defer wg.Done()
+
+			var err error
+			results[i], err = ch.MGet(context.TODO(), batch, fn)
+			assert.Nil(t, err)
+		}(i, batch)
+
+		// source is called after keys of batch are claimed, so next batch waits for them
+		assert.Eventually(t, func() bool { return callCount() == i+1 }, time.Second, time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, [][]int{{1, 2, 3}, {4}, {0}}, calls)
+
+	for i, batch := range batches {
+		for _, k := range batch {
+			if k.OriginalValue == 3 {
+				assert.NotContains(t, results[i], k)
+				continue
+			}
+
+			assert.Equal(t, k.OriginalValue, results[i][k].Id)
+		}
+	}
+}
+
+func TestOneLevelCacheBatchCoalescingSourceError(t *testing.T) {
+	currentModelVersion := uint16(7)
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithBatchCoalescing(true).
+		Build()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	firstDone := make(chan struct{})
+
+	go func() {
+		defer close(firstDone)
+
+		_, err := ch.MGet(context.TODO(), []*Key[int]{key1}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			close(started)
+			<-release
+
+			return nil, errors.New("source is down")
+		})
+		assert.Error(t, err)
+	}()
+
+	<-started
+
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	claimed := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		_, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			assert.Equal(t, []*Key[int]{key2}, keys, "key loaded by concurrent MGet must not be passed to source")
+			close(claimed)
+
+			return nil, nil
+		})
+		done <- err
+	}()
+
+	<-claimed
+	close(release)
+
+	err := <-done
+	assert.ErrorContains(t, err, "source is down")
+
+	<-firstDone
+	assert.Empty(t, ch.batches.inflight)
+}
+
+func TestOneLevelCacheBatchCoalescingOwnerCanceled(t *testing.T) {
+	currentModelVersion := uint16(7)
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithBatchCoalescing(true).
+		WithSourceTimeout(time.Minute).
+		Build()
+
+	ownerCtx, cancelOwner := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ownerDone := make(chan struct{})
+
+	go func() {
+		defer close(ownerDone)
+
+		_, _ = ch.MGet(ownerCtx, []*Key[int]{key1}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			close(started)
+			<-release
+
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, errors.New("shared load must be bounded by source timeout")
+			}
+
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			return map[*Key[int]]*EntityToCache{keys[0]: {Id: 1, ModelVersion: currentModelVersion}}, nil
+		})
+	}()
+
+	<-started
+
+	claimed := make(chan struct{})
+	type result struct {
+		values map[*Key[int]]*EntityToCache
+		err    error
+	}
+	done := make(chan result)
+
+	go func() {
+		values, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			close(claimed)
+
+			return nil, nil
+		})
+		done <- result{values: values, err: err}
+	}()
+
+	<-claimed
+	cancelOwner()
+	close(release)
+
+	r := <-done
+	assert.Nil(t, r.err)
+	assert.Equal(t, 1, r.values[key1].Id)
+
+	<-ownerDone
+}
+
+func TestOneLevelCacheBatchCoalescingWaiterCanceled(t *testing.T) {
+	currentModelVersion := uint16(7)
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithBatchCoalescing(true).
+		Build()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ownerDone := make(chan struct{})
+
+	go func() {
+		defer close(ownerDone)
+
+		_, _ = ch.MGet(context.TODO(), []*Key[int]{key1}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			close(started)
+			<-release
+
+			return nil, nil
+		})
+	}()
+
+	<-started
+
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+
+	_, err := ch.MGet(waiterCtx, []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		cancelWaiter()
+
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	<-ownerDone
+}
+
+func TestOneLevelCacheWriteThrough(t *testing.T) {
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	for name, cacheFirst := range map[string]bool{"source first": false, "cache first": true} {
+		t.Run(name, func(t *testing.T) {
+			provider := NewMapCache[EntityToCache, int]()
+			source := map[string]*EntityToCache{}
+			var writerErr error
+
+			ch := NewCacheBuilder[EntityToCache, int](1, provider).
+				WithWriteThrough(func(ctx context.Context, records map[string]*EntityToCache) error {
+					cached, err := provider.Exists(ctx, key1)
+					assert.Nil(t, err)
+					assert.Equal(t, cacheFirst, cached)
+
+					if writerErr != nil {
+						return writerErr
+					}
+
+					for k, v := range records {
+						source[k] = v
+					}
+
+					return nil
+				}).
+				WithWriteThroughCacheFirst(cacheFirst).
+				Build()
+
+			assert.Nil(t, ch.Set(context.TODO(), key1, &EntityToCache{Id: 1, ModelVersion: 1}))
+			assert.Equal(t, 1, source[key1.Key].Id)
+
+			v, err := ch.Get(context.TODO(), key1, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, v.Id)
+
+			assert.Nil(t, ch.Delete(context.TODO(), key1))
+
+			writerErr = errors.New("source is down")
+			err = ch.MSet(context.TODO(), map[string]*EntityToCache{key1.Key: {Id: 2, ModelVersion: 1}})
+			assert.ErrorIs(t, err, writerErr)
+			assert.Equal(t, 1, source[key1.Key].Id)
+
+			exists, err := provider.Exists(context.TODO(), key1)
+			assert.Nil(t, err)
+			assert.False(t, exists) // cache is not updated when source write fails
+
+			_, err = ch.Refresh(context.TODO(), key1, func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+				return &EntityToCache{Id: 3, ModelVersion: 1}, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, source[key1.Key].Id) // values loaded from source are not written back to it
+		})
+	}
+}
+
+func TestOneLevelCacheWriteThroughCacheFailure(t *testing.T) {
+	_, client := newTestRedis(t)
+	client.AddHook(&failingHook{failures: 1})
+
+	ch := NewCacheBuilder[EntityToCache, int](1, NewRedisCache[EntityToCache, int](client)).
+		WithWriteThrough(func(ctx context.Context, records map[string]*EntityToCache) error {
+			t.Fatalf("source must not be written when providers fail")
+			return nil
+		}).
+		WithWriteThroughCacheFirst(true).
+		Build()
+
+	var msetErr *MSetError
+	assert.ErrorAs(t, ch.MSet(context.TODO(), map[string]*EntityToCache{"key1": {Id: 1, ModelVersion: 1}}), &msetErr)
+}
+
+func TestOneLevelCacheMGetExplicitNilFromSource(t *testing.T) {
+	provider := NewMapCache[EntityToCache, int]()
+	ch := NewCacheBuilder[EntityToCache, int](1, provider).
+		WithNegativeCaching(time.Minute).
+		WithSyncWriteback(true).
+		Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	results, err := ch.MGet(context.TODO(), []*Key[int]{key1, key2}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+		return map[*Key[int]]*EntityToCache{key1: nil, key2: {Id: 2, ModelVersion: 1}}, nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1) // explicit nil is omitted like key not returned by source
+	assert.Equal(t, 2, results[key2].Id)
+
+	_, err = provider.Get(context.TODO(), key1, 1)
+	assert.ErrorIs(t, err, ErrTombstone)
+}
+
+func TestOneLevelCacheWriteThroughStrictVersion(t *testing.T) {
+	ch := NewCacheBuilder[EntityToCache, int](1, NewMapCache[EntityToCache, int]()).
+		WithStrictVersionOnSet(true).
+		WithWriteThrough(func(ctx context.Context, records map[string]*EntityToCache) error {
+			t.Fatalf("source must not be written with records rejected by cache")
+			return nil
+		}).
+		Build()
+
+	err := ch.MSet(context.TODO(), map[string]*EntityToCache{"key1": {Id: 1, ModelVersion: 2}})
+	assert.ErrorIs(t, err, ErrModelVersionMismatch)
+}
+
+func TestOneLevelCacheWriteThroughProviderFailureAfterSource(t *testing.T) {
+	srv, client := newTestRedis(t)
+	provider := NewRedisCache[EntityToCache, int](client)
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{key1.Key: {Id: 1, ModelVersion: 1}}, time.Hour))
+	client.AddHook(&failingHook{failures: 1})
+
+	source := map[string]*EntityToCache{}
+	ch := NewCacheBuilder[EntityToCache, int](1, provider).
+		WithWriteThrough(func(ctx context.Context, records map[string]*EntityToCache) error {
+			for k, v := range records {
+				source[k] = v
+			}
+
+			return nil
+		}).
+		Build()
+
+	var msetErr *MSetError
+	assert.ErrorAs(t, ch.Set(context.TODO(), key1, &EntityToCache{Id: 2, ModelVersion: 1}), &msetErr)
+	assert.Equal(t, 2, source[key1.Key].Id)
+	assert.False(t, srv.Exists(key1.Key)) // previous value is not served after source changed
+}
@@ -0,0 +1,51 @@
+package cache
+
+import "time"
+
+// CallOption overrides builder configuration for single Get or MGet call.
+type CallOption func(o *callOptions)
+
+type callOptions struct {
+	ttl  time.Duration
+	skip []int
+}
+
+// WithCallTtl overrides builder and WithProviderTtl ttl of values written back by this call,
+// ttl of entities implementing TTLProvider still wins. Negative ttl is ignored.
+func WithCallTtl(ttl time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithSkipProviders excludes providers with indexes from reads of this call, e.g. L1 which may be stale
+// after known write. Skipped providers are treated as missing, so they are backfilled with value read
+// from other provider or source.
+func WithSkipProviders(indexes ...int) CallOption {
+	return func(o *callOptions) {
+		o.skip = append(o.skip, indexes...)
+	}
+}
+
+// skips reports whether provider with index is excluded from reads, see WithSkipProviders.
+func (o *callOptions) skips(index int) bool {
+	for _, i := range o.skip {
+		if i == index {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Cache[T, V]) callOptions(opts []CallOption) *callOptions {
+	o := &callOptions{
+		ttl: configuredTtl,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
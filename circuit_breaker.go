@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned by provider writes while circuit breaker is open, see WithCircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker opens after threshold consecutive failures and lets calls through again after cooldown.
+// Nil breaker is always closed.
+type circuitBreaker struct {
+	mut       sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether call may be made, after cooldown calls are let through to probe backend.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	return !b.now().Before(b.openUntil)
+}
+
+// record registers result of call, err is nil for successful ones.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// circuitOpen reports whether provider, possibly wrapped, has open circuit breaker, so writes to it
+// fail with ErrCircuitOpen. Such providers are not written back to, see WithCircuitBreaker.
+func circuitOpen(provider any) bool {
+	p, ok := UnwrapProvider(provider).(interface{ circuitOpen() bool })
+
+	return ok && p.circuitOpen()
+}
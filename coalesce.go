@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// batchCoalescer shares in-flight source loads of MGet per key, see Builder.WithBatchCoalescing.
+type batchCoalescer[T, V any] struct {
+	mut      sync.Mutex
+	inflight map[string]*inflightLoad[T]
+}
+
+// inflightLoad is result of single key loaded by another MGet, available once done is closed.
+type inflightLoad[T any] struct {
+	done   chan struct{}
+	value  *T
+	keyErr error // failure of key reported by GetFromSourceFnWithErrors
+	err    error // failure of whole source call, including ErrDoNotCache
+}
+
+// load calls fn only for keys which are not loaded by concurrent MGet and waits for the rest.
+// Failure of source call which loads waited keys is returned as failure of this call as well.
+// Owned keys are loaded on ctx detached from caller, bounded by timeout when positive, so cancellation of
+// the call does not fail concurrent calls waiting for them, only waiting is given up on cancellation of ctx.
+func (b *batchCoalescer[T, V]) load(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFnWithErrors[T, V],
+	timeout time.Duration,
+) (map[*Key[V]]*T, map[*Key[V]]error, error) {
+	owned, waiting := b.claim(keys)
+
+	var values map[*Key[V]]*T
+	var keyErrs map[*Key[V]]error
+	var err error
+
+	if len(owned) > 0 {
+		sharedCtx := detach(ctx)
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			sharedCtx, cancel = context.WithTimeout(sharedCtx, timeout)
+			defer cancel()
+		}
+
+		values, keyErrs, err = b.loadOwned(sharedCtx, owned, fn)
+	}
+
+	if len(waiting) == 0 || (err != nil && !errors.Is(err, ErrDoNotCache)) {
+		return values, keyErrs, err
+	}
+
+	if values == nil {
+		values = map[*Key[V]]*T{}
+	}
+
+	for k, l := range waiting {
+		select {
+		case <-ctx.Done():
+			return nil, nil, errors.WithStack(ctx.Err())
+		case <-l.done:
+		}
+
+		switch {
+		case l.err != nil && !errors.Is(l.err, ErrDoNotCache):
+			return nil, nil, l.err
+		case l.err != nil && err == nil:
+			err = l.err
+		}
+
+		if l.keyErr != nil {
+			if keyErrs == nil {
+				keyErrs = map[*Key[V]]error{}
+			}
+
+			keyErrs[k] = l.keyErr
+			continue
+		}
+
+		if l.value != nil {
+			values[k] = l.value
+		}
+	}
+
+	return values, keyErrs, err
+}
+
+// loadOwned calls fn for owned keys and releases them even when fn panics, so waiting calls are not blocked.
+func (b *batchCoalescer[T, V]) loadOwned(
+	ctx context.Context,
+	owned []*Key[V],
+	fn GetFromSourceFnWithErrors[T, V],
+) (values map[*Key[V]]*T, keyErrs map[*Key[V]]error, err error) {
+	err = errors.New("source function panicked")
+
+	defer func() {
+		b.release(owned, values, keyErrs, err)
+	}()
+
+	return fn(ctx, owned)
+}
+
+// claim registers loads of keys which are not in flight yet and returns in-flight loads of the rest.
+func (b *batchCoalescer[T, V]) claim(keys []*Key[V]) ([]*Key[V], map[*Key[V]]*inflightLoad[T]) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.inflight == nil {
+		b.inflight = map[string]*inflightLoad[T]{}
+	}
+
+	var owned []*Key[V]
+	var waiting map[*Key[V]]*inflightLoad[T]
+
+	for _, k := range keys {
+		if l, ok := b.inflight[k.Key]; ok {
+			if waiting == nil {
+				waiting = map[*Key[V]]*inflightLoad[T]{}
+			}
+
+			waiting[k] = l
+			continue
+		}
+
+		b.inflight[k.Key] = &inflightLoad[T]{done: make(chan struct{})}
+		owned = append(owned, k)
+	}
+
+	return owned, waiting
+}
+
+// release publishes results of owned keys to waiting MGet calls.
+func (b *batchCoalescer[T, V]) release(owned []*Key[V], values map[*Key[V]]*T, keyErrs map[*Key[V]]error, err error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	for _, k := range owned {
+		l := b.inflight[k.Key]
+		delete(b.inflight, k.Key)
+
+		l.value, l.keyErr, l.err = values[k], keyErrs[k], err
+		close(l.done)
+	}
+}
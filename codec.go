@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// MsgpackCodec encodes entities with msgpack, zero value encodes structs as maps keyed by field name.
+// Unknown fields are skipped and missing ones keep zero value, so fields can be added or removed without
+// model version bump. Decoder detects struct layout on its own, so entries written with and without
+// UseArrayEncodedStructs are read correctly by any MsgpackCodec.
+type MsgpackCodec struct {
+	// UseArrayEncodedStructs encodes structs as arrays of field values without names, which is smaller and faster,
+	// but ties stored entries to field order: bump model version when fields are added, removed or reordered.
+	UseArrayEncodedStructs bool
+	// EncoderFunc customizes encoder before every Marshal, e.g. enc.UseCompactInts(true).
+	EncoderFunc func(enc *msgpack.Encoder)
+	// DecoderFunc customizes decoder before every Unmarshal, e.g. dec.SetMapDecoder(...).
+	DecoderFunc func(dec *msgpack.Decoder)
+}
+
+func (c MsgpackCodec) Marshal(v any) ([]byte, error) {
+	if !c.UseArrayEncodedStructs && c.EncoderFunc == nil {
+		return msgpack.Marshal(v)
+	}
+
+	var buf bytes.Buffer
+
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	enc.Reset(&buf)
+	enc.UseArrayEncodedStructs(c.UseArrayEncodedStructs)
+
+	if c.EncoderFunc != nil {
+		c.EncoderFunc(enc)
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c MsgpackCodec) Unmarshal(data []byte, v any) error {
+	if c.DecoderFunc == nil {
+		return msgpack.Unmarshal(data, v)
+	}
+
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	dec.Reset(bytes.NewReader(data))
+	c.DecoderFunc(dec)
+
+	return dec.Decode(v)
+}
+
+type JSONCodec struct {
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
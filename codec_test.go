@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{MsgpackCodec{}, JSONCodec{}} {
+		bts, err := codec.Marshal(&EntityToCache{
+			Id:           10,
+			Value:        "random_content",
+			ModelVersion: 7,
+		})
+		assert.Nil(t, err)
+
+		var item EntityToCache
+		assert.Nil(t, codec.Unmarshal(bts, &item))
+
+		assert.Equal(t, 10, item.Id)
+		assert.Equal(t, "random_content", item.Value)
+		assert.Equal(t, uint16(7), item.GetCacheModelVersion())
+	}
+}
+
+func TestMsgpackCodecArrayEncodedStructs(t *testing.T) {
+	arrayCodec := MsgpackCodec{UseArrayEncodedStructs: true}
+	entity := &EntityToCache{Id: 10, Value: "random_content", ModelVersion: 7}
+
+	mapBts, err := MsgpackCodec{}.Marshal(entity)
+	assert.Nil(t, err)
+
+	arrayBts, err := arrayCodec.Marshal(entity)
+	assert.Nil(t, err)
+	assert.Less(t, len(arrayBts), len(mapBts))
+
+	for _, tc := range []struct {
+		codec Codec
+		bts   []byte
+	}{
+		{codec: arrayCodec, bts: arrayBts},
+		{codec: arrayCodec, bts: mapBts},
+		{codec: MsgpackCodec{}, bts: arrayBts},
+	} {
+		var item EntityToCache
+		assert.Nil(t, tc.codec.Unmarshal(tc.bts, &item))
+		assert.Equal(t, *entity, item)
+	}
+}
+
+func TestMsgpackCodecHooks(t *testing.T) {
+	encoderCalled := false
+	decoderCalled := false
+
+	codec := MsgpackCodec{
+		EncoderFunc: func(enc *msgpack.Encoder) {
+			encoderCalled = true
+			enc.UseCompactInts(true)
+		},
+		DecoderFunc: func(dec *msgpack.Decoder) {
+			decoderCalled = true
+		},
+	}
+
+	bts, err := codec.Marshal(&EntityToCache{Id: 10, ModelVersion: 7})
+	assert.Nil(t, err)
+
+	var item EntityToCache
+	assert.Nil(t, codec.Unmarshal(bts, &item))
+
+	assert.True(t, encoderCalled)
+	assert.True(t, decoderCalled)
+	assert.Equal(t, 10, item.Id)
+}
+
+func BenchmarkMsgpackCodec(b *testing.B) {
+	entity := &EntityToCache{Id: 10, Value: "random_content", ModelVersion: 7}
+
+	for name, codec := range map[string]MsgpackCodec{
+		"map":   {},
+		"array": {UseArrayEncodedStructs: true},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var size int
+
+			for i := 0; i < b.N; i++ {
+				bts, err := codec.Marshal(entity)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var item EntityToCache
+				if err = codec.Unmarshal(bts, &item); err != nil {
+					b.Fatal(err)
+				}
+
+				size = len(bts)
+			}
+
+			b.ReportMetric(float64(size), "bytes/entry")
+		})
+	}
+}
+
+type entityWithExtraField struct {
+	Id           int
+	Value        string
+	ModelVersion uint16
+	Lang         string
+}
+
+func TestCodecsSchemaEvolution(t *testing.T) {
+	for _, codec := range []Codec{MsgpackCodec{}, JSONCodec{}} {
+		bts, err := codec.Marshal(&entityWithExtraField{Id: 10, Value: "random_content", ModelVersion: 7, Lang: "en"})
+		assert.Nil(t, err)
+
+		var old EntityToCache
+		assert.Nil(t, codec.Unmarshal(bts, &old))
+		assert.Equal(t, EntityToCache{Id: 10, Value: "random_content", ModelVersion: 7}, old)
+
+		bts, err = codec.Marshal(&EntityToCache{Id: 10, Value: "random_content", ModelVersion: 7})
+		assert.Nil(t, err)
+
+		var extended entityWithExtraField
+		assert.Nil(t, codec.Unmarshal(bts, &extended))
+		assert.Equal(t, entityWithExtraField{Id: 10, Value: "random_content", ModelVersion: 7}, extended)
+	}
+}
+
+func TestMsgpackCodecArrayEncodedSchemaChange(t *testing.T) {
+	codec := MsgpackCodec{UseArrayEncodedStructs: true}
+
+	bts, err := codec.Marshal(&entityWithExtraField{Id: 10, ModelVersion: 7, Lang: "en"})
+	assert.Nil(t, err)
+
+	var old EntityToCache
+	assert.NotNil(t, codec.Unmarshal(bts, &old))
+
+	strict := MsgpackCodec{
+		DecoderFunc: func(dec *msgpack.Decoder) {
+			dec.DisallowUnknownFields(true)
+		},
+	}
+
+	bts, err = MsgpackCodec{}.Marshal(&entityWithExtraField{Id: 10, ModelVersion: 7, Lang: "en"})
+	assert.Nil(t, err)
+	assert.ErrorContains(t, strict.Unmarshal(bts, &old), "unknown field")
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+type CompressionAlgorithm byte
+
+// Compressed entries start with tombstoneMarker followed by algorithm. Bundled codecs never produce that byte,
+// so entries written without compression are read as is, custom Codec must not produce it either.
+// Tombstones share the marker, but are 3 bytes long, which is shorter than any compressed entry.
+const (
+	CompressionNone CompressionAlgorithm = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+
+	return zstdErr
+}
+
+func compress(algorithm CompressionAlgorithm, data []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/2+1))
+		buf.Write([]byte{tombstoneMarker, byte(CompressionGzip)})
+
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return zstdEncoder.EncodeAll(data, []byte{tombstoneMarker, byte(CompressionZstd)}), nil
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm %v", algorithm)
+	}
+}
+
+func decompress(data []byte) ([]byte, error) {
+	if len(data) <= 3 || data[0] != tombstoneMarker { // not compressed
+		return data, nil
+	}
+
+	switch CompressionAlgorithm(data[1]) {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[2:]))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		defer func() {
+			_ = r.Close()
+		}()
+
+		res, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return res, nil
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		res, err := zstdDecoder.DecodeAll(data[2:], nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return res, nil
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm %v", data[1])
+	}
+}
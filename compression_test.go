@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func largeEntity() *EntityToCache {
+	return &EntityToCache{
+		Id:           10,
+		Value:        strings.Repeat(`{"translation":"some long translated text","lang":"en"},`, 200),
+		ModelVersion: 7,
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	raw, err := MsgpackCodec{}.Marshal(largeEntity())
+	assert.Nil(t, err)
+
+	for _, algorithm := range []CompressionAlgorithm{CompressionNone, CompressionGzip, CompressionZstd} {
+		compressed, err := compress(algorithm, raw)
+		assert.Nil(t, err)
+
+		if algorithm != CompressionNone {
+			assert.Less(t, len(compressed), len(raw))
+		}
+
+		decompressed, err := decompress(compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, raw, decompressed)
+	}
+}
+
+func TestDecompressLegacyEntries(t *testing.T) {
+	for _, codec := range []Codec{MsgpackCodec{}, JSONCodec{}} {
+		raw, err := codec.Marshal(largeEntity())
+		assert.Nil(t, err)
+
+		decompressed, err := decompress(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, raw, decompressed)
+	}
+}
+
+type counterEntity int
+
+func (c counterEntity) GetCacheModelVersion() uint16 {
+	return 0
+}
+
+func TestDecompressEntriesStartingWithAlgorithm(t *testing.T) {
+	for _, v := range []counterEntity{counterEntity(CompressionGzip), counterEntity(CompressionZstd)} {
+		raw, err := MsgpackCodec{}.Marshal(v)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{byte(v)}, raw)
+
+		decompressed, err := decompress(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, raw, decompressed)
+	}
+
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		compressed, err := compress(algorithm, []byte{1})
+		assert.Nil(t, err)
+		assert.False(t, isTombstone(compressed))
+		assert.Greater(t, len(compressed), 11) // longer than tombstone with expiry of StoreProvider
+
+		decompressed, err := decompress(compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{1}, decompressed)
+	}
+}
+
+func BenchmarkCompression(b *testing.B) {
+	raw, err := MsgpackCodec{}.Marshal(largeEntity())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for name, algorithm := range map[string]CompressionAlgorithm{
+		"none": CompressionNone,
+		"gzip": CompressionGzip,
+		"zstd": CompressionZstd,
+	} {
+		b.Run(name, func(b *testing.B) {
+			var size int
+
+			for i := 0; i < b.N; i++ {
+				compressed, err := compress(algorithm, raw)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err = decompress(compressed); err != nil {
+					b.Fatal(err)
+				}
+
+				size = len(compressed)
+			}
+
+			b.ReportMetric(float64(size), "bytes/op-stored")
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTombstone is returned by Provider.Get when key is negatively cached for required model version.
+var ErrTombstone = errors.New("key is negatively cached")
+
+// ErrNoSourceFn is returned when value has to be loaded from source, but neither fn, loader of WithLoader
+// nor default loader is set.
+var ErrNoSourceFn = errors.New("source function is not defined")
+
+// ErrDoNotCache can be returned by source function along with value which must not be cached, e.g. default
+// served during outage. Get and MGet return such value without error and skip writing it and tombstones back.
+var ErrDoNotCache = errors.New("source value must not be cached")
+
+// ErrUpdateNotSupported is returned by Cache.Update when no provider implements Updater.
+var ErrUpdateNotSupported = errors.New("no provider supports atomic update")
+
+// ErrTTLNotSupported is returned by Cache.TTL when no provider implements TTLReader.
+var ErrTTLNotSupported = errors.New("no provider supports ttl")
+
+// ErrDeleteByPrefixNotSupported is returned by Cache.DeleteByPrefix when no provider implements PrefixDeleter.
+var ErrDeleteByPrefixNotSupported = errors.New("no provider supports delete by prefix")
+
+// ErrNoProviders is panic value of Builder.MustBuild when cache has no providers.
+var ErrNoProviders = errors.New("cache has no providers")
+
+// ErrClosed is returned by Cache operations after Cache.Close.
+var ErrClosed = errors.New("cache is closed")
+
+// ErrModelVersionMismatch is returned when entity model version differs from version configured in builder.
+var ErrModelVersionMismatch = errors.New("entity model version does not match cache model version")
+
+// ErrClearNotSupported is returned by Provider.Clear of providers which can not enumerate their keys,
+// e.g. MemcachedCache, StoreProvider and RedisCache without key prefix. Bump model version to invalidate entries instead.
+var ErrClearNotSupported = errors.New("provider can not be cleared, bump model version instead")
+
+// KeyTooLongError is returned by MemcachedCache for key which is longer than memcached allows, including key prefix.
+type KeyTooLongError struct {
+	Key       string
+	MaxLength int
+}
+
+func (e *KeyTooLongError) Error() string {
+	return fmt.Sprintf("key is %v bytes long, at most %v bytes are allowed", len(e.Key), e.MaxLength)
+}
+
+// FailedKeysError is returned by Provider.MSet when only part of values was written.
+// Any other error means none of values was written.
+type FailedKeysError struct {
+	Keys []string
+	Err  error
+}
+
+func (e *FailedKeysError) Error() string {
+	return fmt.Sprintf("can not set %v keys: %v", len(e.Keys), e.Err)
+}
+
+func (e *FailedKeysError) Unwrap() error {
+	return e.Err
+}
+
+// SourceError wraps error returned by source function, configuration errors are never wrapped into it.
+type SourceError struct {
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	return "can not get from source: " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ProviderFailure describes keys not written to provider with index ProviderIndex in builder.
+type ProviderFailure struct {
+	ProviderIndex int
+	Provider      string
+	Keys          []string
+	Err           error
+}
+
+// MSetError is returned by Cache.MSet, so failed subset can be retried. Use errors.As to get it.
+type MSetError struct {
+	Failures []ProviderFailure
+}
+
+func (e *MSetError) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+
+	for _, f := range e.Failures {
+		messages = append(messages, fmt.Sprintf("provider %v (%v keys): %v", f.Provider, len(f.Keys), f.Err))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *MSetError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+
+	return errs
+}
+
+func newProviderFailure[T any](index int, provider any, records map[string]*T, err error) ProviderFailure {
+	var keys []string
+
+	var failedKeys *FailedKeysError
+	if errors.As(err, &failedKeys) {
+		keys = failedKeys.Keys
+	} else {
+		keys = make([]string, 0, len(records))
+		for k := range records {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return ProviderFailure{
+		ProviderIndex: index,
+		Provider:      providerName(provider),
+		Keys:          keys,
+		Err:           err,
+	}
+}
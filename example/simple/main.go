@@ -3,9 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
-	"github.com/rs/zerolog"
+	"github.com/redis/go-redis/v9"
 	cache "github.com/skynet2/datasource-cache"
 )
 
@@ -18,7 +19,7 @@ func main() {
 		redisClient)
 
 	cacheManager := cache.NewCacheBuilder[TranslatedEntity, string](
-		ModelVersion, redisCacheProvider).Build()
+		ModelVersion, redisCacheProvider).MustBuild()
 
 	tr := &translateService{cacheManager: cacheManager, dbRepo: &dbRepo{}}
 
@@ -93,7 +94,7 @@ func (t *translateService) GetTranslation(
 			v, ok := reverseMap[key]
 
 			if !ok {
-				zerolog.Ctx(ctx).Warn().Msgf("not found in reverse map key %v", key)
+				slog.WarnContext(ctx, "not found in reverse map", "key", key)
 				continue
 			}
 
@@ -0,0 +1,41 @@
+package cache
+
+import "context"
+
+// loaderKey is context key of loaders, distinct per entity and key type.
+type loaderKey[T, V any] struct{}
+
+type contextLoaders[T, V any] struct {
+	single GetSingleFromSourceFn[T, V]
+	multi  GetFromSourceFn[T, V]
+}
+
+// WithLoader attaches source functions to ctx, e.g. in middleware closing over request scoped DB handle.
+// Get, MGet, Refresh and MRefresh of Cache with the same entity and key types use them when nil fn is passed,
+// they take precedence over Builder.WithDefaultLoader. Nil loader falls back to default one.
+// RawCache also uses loaders typed on raw value, e.g. WithLoader[string, int].
+func WithLoader[T, V any](
+	ctx context.Context,
+	single GetSingleFromSourceFn[T, V],
+	multi GetFromSourceFn[T, V],
+) context.Context {
+	return context.WithValue(ctx, loaderKey[T, V]{}, contextLoaders[T, V]{single: single, multi: multi})
+}
+
+// singleLoader returns loader of ctx or default one, nil when neither is set.
+func (c *Cache[T, V]) singleLoader(ctx context.Context) GetSingleFromSourceFn[T, V] {
+	if l, ok := ctx.Value(loaderKey[T, V]{}).(contextLoaders[T, V]); ok && l.single != nil {
+		return l.single
+	}
+
+	return c.builder.singleLoader
+}
+
+// multiLoader returns loader of ctx or default one, nil when neither is set.
+func (c *Cache[T, V]) multiLoader(ctx context.Context) GetFromSourceFn[T, V] {
+	if l, ok := ctx.Value(loaderKey[T, V]{}).(contextLoaders[T, V]); ok && l.multi != nil {
+		return l.multi
+	}
+
+	return c.builder.multiLoader
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger receives errors which are not returned to caller. Warn is used for corrupted cache entries,
+// Error for failing providers.
+type Logger interface {
+	Warn(err error, msg string, attrs ...slog.Attr)
+	Error(err error, msg string, attrs ...slog.Attr)
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger adapts slog logger to Logger, slog.Default at the time of logging is used for nil logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) Warn(err error, msg string, attrs ...slog.Attr) {
+	l.log(slog.LevelWarn, err, msg, attrs)
+}
+
+func (l slogLogger) Error(err error, msg string, attrs ...slog.Attr) {
+	l.log(slog.LevelError, err, msg, attrs)
+}
+
+func (l slogLogger) log(level slog.Level, err error, msg string, attrs []slog.Attr) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger.LogAttrs(context.Background(), level, msg, append(attrs, slog.Any("error", err))...)
+}
+
+func keyCount(count int) slog.Attr {
+	return slog.Int("key_count", count)
+}
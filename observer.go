@@ -0,0 +1,58 @@
+package cache
+
+import "time"
+
+const (
+	OperationGet      = "get"
+	OperationMGet     = "mget"
+	OperationRefresh  = "refresh"
+	OperationMRefresh = "mrefresh"
+)
+
+// Observer receives cache events, counts for OperationMGet and OperationMRefresh are per key.
+type Observer interface {
+	Hit(operation string, count int)
+	Miss(operation string, count int)
+	SourceCall(operation string, duration time.Duration, err error)
+	SetFailure(operation string)
+}
+
+type noopObserver struct {
+}
+
+func (noopObserver) Hit(string, int)                         {}
+func (noopObserver) Miss(string, int)                        {}
+func (noopObserver) SourceCall(string, time.Duration, error) {}
+func (noopObserver) SetFailure(string)                       {}
+
+func (c *Cache[T, V]) recordHits(operation string, count int) {
+	if count == 0 {
+		return
+	}
+
+	c.stats.hits.Add(uint64(count))
+	c.builder.observer.Hit(operation, count)
+}
+
+func (c *Cache[T, V]) recordMisses(operation string, count int) {
+	if count == 0 {
+		return
+	}
+
+	c.stats.misses.Add(uint64(count))
+	c.builder.observer.Miss(operation, count)
+}
+
+func (c *Cache[T, V]) recordSourceCall(operation string, start time.Time, err error) {
+	c.stats.sourceCalls.Add(1)
+
+	if err != nil {
+		c.stats.sourceErrors.Add(1)
+	}
+
+	c.builder.observer.SourceCall(operation, time.Since(start), err)
+}
+
+func (c *Cache[T, V]) recordSetFailure(operation string) {
+	c.builder.observer.SetFailure(operation)
+}
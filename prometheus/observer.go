@@ -0,0 +1,105 @@
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	cache "github.com/skynet2/datasource-cache"
+)
+
+type Observer struct {
+	hits           *prom.CounterVec
+	misses         *prom.CounterVec
+	sourceCalls    *prom.CounterVec
+	sourceErrors   *prom.CounterVec
+	setFailures    *prom.CounterVec
+	sourceDuration *prom.HistogramVec
+}
+
+// NewObserver registers cache metrics labeled by operation (get / mget / refresh / mrefresh) in registerer.
+func NewObserver(registerer prom.Registerer, namespace string) (*Observer, error) {
+	labels := []string{"operation"}
+
+	o := &Observer{
+		hits: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "hits_total",
+			Help:      "Number of keys served by cache providers.",
+		}, labels),
+		misses: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "misses_total",
+			Help:      "Number of keys missing in all cache providers.",
+		}, labels),
+		sourceCalls: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "source_calls_total",
+			Help:      "Number of data source calls.",
+		}, labels),
+		sourceErrors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "source_errors_total",
+			Help:      "Number of failed data source calls.",
+		}, labels),
+		setFailures: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "set_failures_total",
+			Help:      "Number of failed writes to cache providers.",
+		}, labels),
+		sourceDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "source_duration_seconds",
+			Help:      "Latency of data source calls.",
+			Buckets:   prom.DefBuckets,
+		}, labels),
+	}
+
+	for _, c := range []prom.Collector{o.hits, o.misses, o.sourceCalls, o.sourceErrors, o.setFailures, o.sourceDuration} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// WithPrometheus registers metrics and attaches them to builder.
+func WithPrometheus[T, V any](
+	builder *cache.Builder[T, V],
+	registerer prom.Registerer,
+	namespace string,
+) (*cache.Builder[T, V], error) {
+	o, err := NewObserver(registerer, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return builder.WithObserver(o), nil
+}
+
+func (o *Observer) Hit(operation string, count int) {
+	o.hits.WithLabelValues(operation).Add(float64(count))
+}
+
+func (o *Observer) Miss(operation string, count int) {
+	o.misses.WithLabelValues(operation).Add(float64(count))
+}
+
+func (o *Observer) SourceCall(operation string, duration time.Duration, err error) {
+	o.sourceCalls.WithLabelValues(operation).Inc()
+	o.sourceDuration.WithLabelValues(operation).Observe(duration.Seconds())
+
+	if err != nil {
+		o.sourceErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (o *Observer) SetFailure(operation string) {
+	o.setFailures.WithLabelValues(operation).Inc()
+}
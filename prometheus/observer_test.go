@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	cache "github.com/skynet2/datasource-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type entity struct {
+	Value        string
+	ModelVersion uint16
+}
+
+func (e entity) GetCacheModelVersion() uint16 {
+	return e.ModelVersion
+}
+
+func TestObserver(t *testing.T) {
+	registry := prom.NewRegistry()
+
+	o, err := NewObserver(registry, "test")
+	assert.Nil(t, err)
+
+	ch := cache.NewCacheBuilder[entity, string](1, cache.NewLRUCache[entity, string](10, time.Minute)).
+		WithObserver(o).
+		Build()
+
+	key := &cache.Key[string]{Key: "key", OriginalValue: "key"}
+
+	for i := 0; i < 2; i++ {
+		_, err = ch.Get(context.TODO(), key, func(ctx context.Context, key *cache.Key[string]) (*entity, error) {
+			return &entity{Value: key.OriginalValue, ModelVersion: 1}, nil
+		})
+		assert.Nil(t, err)
+	}
+
+	_, err = ch.MGet(context.TODO(), []*cache.Key[string]{{Key: "key2"}, {Key: "key3"}},
+		func(ctx context.Context, keys []*cache.Key[string]) (map[*cache.Key[string]]*entity, error) {
+			return nil, errors.New("source is down")
+		})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(o.hits.WithLabelValues(cache.OperationGet)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(o.misses.WithLabelValues(cache.OperationGet)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(o.sourceCalls.WithLabelValues(cache.OperationGet)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(o.misses.WithLabelValues(cache.OperationMGet)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(o.sourceErrors.WithLabelValues(cache.OperationMGet)))
+	assert.Equal(t, 2, testutil.CollectAndCount(o.sourceDuration))
+}
+
+func TestWithPrometheusDuplicateRegistration(t *testing.T) {
+	registry := prom.NewRegistry()
+
+	_, err := WithPrometheus(cache.NewCacheBuilder[entity, string](1), registry, "test")
+	assert.Nil(t, err)
+
+	_, err = WithPrometheus(cache.NewCacheBuilder[entity, string](1), registry, "test")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	promotionSketchDepth = 4
+	promotionSketchWidth = 1 << 12
+	// promotionSampleSize is amount of recorded hits after which counters are halved,
+	// so keys which stopped being hot are forgotten.
+	promotionSampleSize = promotionSketchWidth * 10
+)
+
+// promotionSketch is count-min sketch of provider hits used by WithPromotionThreshold.
+// It over-estimates counts on collisions, so cold key is promoted early at worst.
+type promotionSketch struct {
+	mut       sync.Mutex
+	seed      maphash.Seed
+	threshold uint32
+	samples   int
+	counters  [promotionSketchDepth][promotionSketchWidth]uint32
+}
+
+func newPromotionSketch(threshold int) *promotionSketch {
+	if threshold <= 1 {
+		return nil
+	}
+
+	return &promotionSketch{
+		seed:      maphash.MakeSeed(),
+		threshold: uint32(threshold),
+	}
+}
+
+// hit records provider hit of key and reports whether key reached threshold, nil sketch promotes every key.
+func (s *promotionSketch) hit(key string) bool {
+	if s == nil {
+		return true
+	}
+
+	h := maphash.String(s.seed, key)
+	h1, h2 := uint32(h), uint32(h>>32)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	estimate := ^uint32(0)
+
+	for i := range s.counters {
+		idx := (h1 + uint32(i)*h2) % promotionSketchWidth
+
+		s.counters[i][idx]++
+		estimate = min(estimate, s.counters[i][idx])
+	}
+
+	if s.samples++; s.samples >= promotionSampleSize {
+		s.reset()
+	}
+
+	return estimate >= s.threshold
+}
+
+func (s *promotionSketch) reset() {
+	s.samples /= 2
+
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j] /= 2
+		}
+	}
+}
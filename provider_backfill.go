@@ -0,0 +1,34 @@
+package cache
+
+type backfillOnlyProvider[T, V any] struct {
+	Provider[T, V]
+}
+
+// WithBackfillOnly marks provider to be populated only by values loaded from source or found in other providers,
+// including Cache.Refresh, MRefresh, Warm and Import. Cache.MSet and Set delete written keys from it instead
+// of writing them, so it never serves stale value.
+func WithBackfillOnly[T, V any](provider Provider[T, V]) Provider[T, V] {
+	return &backfillOnlyProvider[T, V]{
+		Provider: provider,
+	}
+}
+
+func (p *backfillOnlyProvider[T, V]) backfillOnly() {}
+
+func (p *backfillOnlyProvider[T, V]) unwrap() any {
+	return p.Provider
+}
+
+// isBackfillOnly reports whether provider or any provider wrapped by it is marked with WithBackfillOnly.
+func isBackfillOnly(provider any) bool {
+	for {
+		switch p := provider.(type) {
+		case interface{ backfillOnly() }:
+			return true
+		case interface{ unwrap() any }:
+			provider = p.unwrap()
+		default:
+			return false
+		}
+	}
+}
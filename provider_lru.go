@@ -0,0 +1,546 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const DefaultLRUTtl = time.Hour
+
+type LRUCache[T Entity, V any] struct {
+	mut        sync.Mutex
+	size       int
+	maxBytes   int64
+	bytes      int64
+	sizeOf     func(value *T) int64
+	ttl        time.Duration
+	slidingTtl time.Duration
+	keepStale  bool
+	skipSame   bool
+	onEvict    func(key string, value *T)
+	locks      keyLocks
+	items      map[string]*list.Element
+	evictList  *list.List
+	now        func() time.Time
+}
+
+type lruEntry[T any] struct {
+	key          string
+	value        *T
+	expiresAt    time.Time
+	tombstone    bool
+	modelVersion uint16
+	bytes        int64
+}
+
+// NewLRUCache creates in-memory provider holding up to size entries.
+// ttl is used for entries written with zero ttl, DefaultLRUTtl is used when ttl is not positive.
+// Only WithSlidingExpiration and WithKeepStaleVersions are applicable from provider options,
+// see LRUCache.WithEvictionCallback for eviction callback.
+func NewLRUCache[T Entity, V any](
+	size int,
+	ttl time.Duration,
+	opts ...ProviderOption,
+) *LRUCache[T, V] {
+	if ttl <= 0 {
+		ttl = DefaultLRUTtl
+	}
+
+	o := newProviderOptions(opts...)
+
+	return &LRUCache[T, V]{
+		size:       size,
+		ttl:        ttl,
+		slidingTtl: o.slidingTtl,
+		keepStale:  o.keepStale,
+		skipSame:   o.skipUnchanged,
+		items:      map[string]*list.Element{},
+		evictList:  list.New(),
+		now:        time.Now,
+	}
+}
+
+// NewLRUCacheWithMemoryLimit creates in-memory provider evicting least recently used entries
+// while approximate size of stored entries exceeds maxBytes. sizeOf estimates size of value,
+// length of msgpack encoded value is used when it is nil. Entry bigger than maxBytes is not kept.
+func NewLRUCacheWithMemoryLimit[T Entity, V any](
+	maxBytes int64,
+	sizeOf func(value *T) int64,
+	ttl time.Duration,
+	opts ...ProviderOption,
+) *LRUCache[T, V] {
+	if sizeOf == nil {
+		sizeOf = msgpackSize[T]
+	}
+
+	c := NewLRUCache[T, V](0, ttl, opts...)
+	c.maxBytes = maxBytes
+	c.sizeOf = sizeOf
+
+	return c
+}
+
+// WithEvictionCallback calls fn for every entry evicted due to size pressure, value is nil for tombstones.
+// fn is called under cache lock and must not use the cache. Returns c for chaining after NewLRUCache.
+func (c *LRUCache[T, V]) WithEvictionCallback(fn func(key string, value *T)) *LRUCache[T, V] {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.onEvict = fn
+
+	return c
+}
+
+func msgpackSize[T any](value *T) int64 {
+	b, err := msgpack.Marshal(value)
+	if err != nil {
+		return 0
+	}
+
+	return int64(len(b))
+}
+
+func (c *LRUCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	item, tombstone := c.get(key.Key, requiredModelVersion)
+	if tombstone {
+		return nil, ErrTombstone
+	}
+
+	return item, nil
+}
+
+func (c *LRUCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var missing []*Key[V]
+	results := map[*Key[V]]*T{}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		item, tombstone := c.get(key.Key, requiredModelVersion)
+
+		if item == nil && !tombstone {
+			missing = append(missing, key)
+			continue
+		}
+
+		results[key] = item
+	}
+
+	return results, missing, nil
+}
+
+// Ping always succeeds for in-memory cache, see Pinger.
+func (c *LRUCache[T, V]) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// TTL returns remaining ttl of not expired entry regardless of its model version, see TTLReader.
+// Recency of entry is not updated.
+func (c *LRUCache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return TTLNotFound, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.contains(key.Key) {
+		return TTLNotFound, nil
+	}
+
+	return c.items[key.Key].Value.(*lruEntry[T]).expiresAt.Sub(c.now()), nil
+}
+
+func (c *LRUCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.contains(key.Key), nil
+}
+
+func (c *LRUCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+
+	for k, v := range values {
+		if c.skipSame && c.holds(k, v) {
+			c.items[k].Value.(*lruEntry[T]).expiresAt = expiresAt
+			continue
+		}
+
+		c.add(&lruEntry[T]{
+			key:       k,
+			value:     v,
+			expiresAt: expiresAt,
+		})
+	}
+
+	return nil
+}
+
+func (c *LRUCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+
+	for _, k := range keys {
+		c.add(&lruEntry[T]{
+			key:          k,
+			expiresAt:    expiresAt,
+			tombstone:    true,
+			modelVersion: modelVersion,
+		})
+	}
+
+	return nil
+}
+
+// Update runs fn under per-key lock, see Updater. Only concurrent updates of key are serialized,
+// MSet and Delete are not blocked by it. fn is called without cache lock, so it may use the cache.
+func (c *LRUCache[T, V]) Update(
+	ctx context.Context,
+	key *Key[V],
+	modelVersion uint16,
+	ttl time.Duration,
+	fn func(current *T) (*T, error),
+) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unlock := c.locks.lock(key.Key)
+	defer unlock()
+
+	c.mut.Lock()
+	current, _ := c.get(key.Key, modelVersion)
+	c.mut.Unlock()
+
+	next, err := fn(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if next == nil {
+		return nil, c.Delete(ctx, key)
+	}
+
+	return next, c.MSet(ctx, map[string]*T{key.Key: next}, ttl)
+}
+
+// DeleteByPrefix removes keys starting with prefix and returns amount of removed keys, see PrefixDeleter.
+func (c *LRUCache[T, V]) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	removed := 0
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func (c *LRUCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key.Key]; ok {
+			c.removeElement(el)
+		}
+	}
+
+	return nil
+}
+
+func (c *LRUCache[T, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.items = map[string]*list.Element{}
+	c.evictList.Init()
+	c.bytes = 0
+
+	return nil
+}
+
+// Len returns amount of stored entries, expired entries are counted until they are touched or evicted.
+func (c *LRUCache[T, V]) Len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.evictList.Len()
+}
+
+// Cap returns maximum amount of entries, 0 means unbounded.
+func (c *LRUCache[T, V]) Cap() int {
+	return c.size
+}
+
+// Bytes returns approximate size of stored entries, it is tracked only for NewLRUCacheWithMemoryLimit.
+func (c *LRUCache[T, V]) Bytes() int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.bytes
+}
+
+// Entries returns stored keys from most to least recently used, intended for debugging.
+func (c *LRUCache[T, V]) Entries() []string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	keys := make([]string, 0, c.evictList.Len())
+
+	for el := c.evictList.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*lruEntry[T]).key)
+	}
+
+	return keys
+}
+
+// Scan calls fn for not expired keys starting with prefix from most to least recently used, see Scanner.
+// fn is called after keys are collected, so it may use the cache.
+func (c *LRUCache[T, V]) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+
+	var keys []string
+	now := c.now()
+
+	for el := c.evictList.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*lruEntry[T])
+
+		if now.Before(entry.expiresAt) && strings.HasPrefix(entry.key, prefix) {
+			keys = append(keys, entry.key)
+		}
+	}
+
+	c.mut.Unlock()
+
+	for _, k := range keys {
+		if !fn(k) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *LRUCache[T, V]) get(key string, requiredModelVersion uint16) (*T, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry[T])
+
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+
+	if entry.tombstone {
+		if !versionMatches(entry.modelVersion, requiredModelVersion) {
+			c.removeStale(el)
+			return nil, false
+		}
+
+		c.evictList.MoveToFront(el) // tombstone expiry is not extended, so key is reloaded once it expires
+
+		return nil, true
+	}
+
+	if entry.value == nil || !versionMatches((*entry.value).GetCacheModelVersion(), requiredModelVersion) {
+		c.removeStale(el)
+		return nil, false
+	}
+
+	c.touch(el, entry)
+
+	return entry.value, false
+}
+
+// contains checks presence of not expired entry without updating recency.
+func (c *LRUCache[T, V]) contains(key string) bool {
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	if !c.now().Before(el.Value.(*lruEntry[T]).expiresAt) {
+		c.removeElement(el)
+		return false
+	}
+
+	return true
+}
+
+// holds reports whether not expired entry of key has value equal to v, see WithSkipUnchangedWrites.
+func (c *LRUCache[T, V]) holds(key string, v *T) bool {
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	entry := el.Value.(*lruEntry[T])
+
+	return !entry.tombstone && c.now().Before(entry.expiresAt) && reflect.DeepEqual(entry.value, v)
+}
+
+func (c *LRUCache[T, V]) removeStale(el *list.Element) {
+	if !c.keepStale {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRUCache[T, V]) touch(el *list.Element, entry *lruEntry[T]) {
+	if c.slidingTtl > 0 {
+		entry.expiresAt = c.now().Add(c.slidingTtl)
+	}
+
+	c.evictList.MoveToFront(el)
+}
+
+func (c *LRUCache[T, V]) add(entry *lruEntry[T]) {
+	if c.sizeOf != nil {
+		entry.bytes = int64(len(entry.key))
+
+		if entry.value != nil {
+			entry.bytes += c.sizeOf(entry.value)
+		}
+	}
+
+	c.bytes += entry.bytes
+
+	if el, ok := c.items[entry.key]; ok {
+		c.bytes -= el.Value.(*lruEntry[T]).bytes
+		el.Value = entry
+		c.evictList.MoveToFront(el)
+	} else {
+		c.items[entry.key] = c.evictList.PushFront(entry)
+	}
+
+	for (c.size > 0 && c.evictList.Len() > c.size) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		oldest := c.evictList.Back()
+		c.removeElement(oldest)
+
+		if c.onEvict != nil {
+			evicted := oldest.Value.(*lruEntry[T])
+			c.onEvict(evicted.key, evicted.value)
+		}
+	}
+}
+
+func (c *LRUCache[T, V]) removeElement(el *list.Element) {
+	entry := el.Value.(*lruEntry[T])
+
+	c.evictList.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= entry.bytes
+}
+
+// keyLocks is set of mutexes per key, which are released once no goroutine holds or waits for them.
+type keyLocks struct {
+	mut   sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (l *keyLocks) lock(key string) func() {
+	l.mut.Lock()
+
+	if l.locks == nil {
+		l.locks = map[string]*keyLock{}
+	}
+
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[key] = kl
+	}
+
+	kl.refs++
+	l.mut.Unlock()
+
+	kl.Lock()
+
+	return func() {
+		kl.Unlock()
+
+		l.mut.Lock()
+		defer l.mut.Unlock()
+
+		if kl.refs--; kl.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ShardedLRUCache spreads keys over independent LRUCache shards by hash of key, so concurrent calls
+// contend only for lock of their shard. Each shard evicts on its own when it holds totalSize/shards entries,
+// so entry may be evicted while other shards have free space and total amount is bounded only approximately.
+type ShardedLRUCache[T Entity, V any] struct {
+	shards []*LRUCache[T, V]
+}
+
+// NewShardedLRUCache creates in-memory provider of shards LRUCache instances sharing totalSize,
+// ttl and provider options are passed to every shard as to NewLRUCache. Non positive shards means single shard.
+func NewShardedLRUCache[T Entity, V any](
+	shards int,
+	totalSize int,
+	ttl time.Duration,
+	opts ...ProviderOption,
+) *ShardedLRUCache[T, V] {
+	if shards <= 0 {
+		shards = 1
+	}
+
+	size := 0
+	if totalSize > 0 {
+		size = max(totalSize/shards, 1)
+	}
+
+	c := &ShardedLRUCache[T, V]{
+		shards: make([]*LRUCache[T, V], shards),
+	}
+
+	for i := range c.shards {
+		c.shards[i] = NewLRUCache[T, V](size, ttl, opts...)
+	}
+
+	return c
+}
+
+func (c *ShardedLRUCache[T, V]) shard(key string) *LRUCache[T, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+
+	return c.shards[fnv32a(key)%uint32(len(c.shards))]
+}
+
+// fnv32a is FNV-1a hash of key, inlined to avoid allocation of hash.Hash32 on every call.
+func fnv32a(key string) uint32 {
+	h := uint32(2166136261)
+
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+
+	return h
+}
+
+func (c *ShardedLRUCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	return c.shard(key.Key).Get(ctx, key, requiredModelVersion)
+}
+
+// MGet reads keys of every shard under single lock of the shard, missing keys keep order of keys.
+func (c *ShardedLRUCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	byShard := map[*LRUCache[T, V]][]*Key[V]{}
+
+	for _, k := range keys {
+		s := c.shard(k.Key)
+		byShard[s] = append(byShard[s], k)
+	}
+
+	results := make(map[*Key[V]]*T, len(keys))
+	missingSet := map[*Key[V]]struct{}{}
+
+	for s, shardKeys := range byShard {
+		found, missing, err := s.MGet(ctx, shardKeys, requiredModelVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range found {
+			results[k] = v
+		}
+
+		for _, k := range missing {
+			missingSet[k] = struct{}{}
+		}
+	}
+
+	var missing []*Key[V]
+
+	for _, k := range keys {
+		if _, ok := missingSet[k]; ok {
+			missing = append(missing, k)
+		}
+	}
+
+	return results, missing, nil
+}
+
+func (c *ShardedLRUCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if len(values) == 1 {
+		for k := range values {
+			return c.shard(k).MSet(ctx, values, ttl)
+		}
+	}
+
+	byShard := map[*LRUCache[T, V]]map[string]*T{}
+
+	for k, v := range values {
+		s := c.shard(k)
+
+		if byShard[s] == nil {
+			byShard[s] = map[string]*T{}
+		}
+
+		byShard[s][k] = v
+	}
+
+	for s, shardValues := range byShard {
+		if err := s.MSet(ctx, shardValues, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ShardedLRUCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	byShard := map[*LRUCache[T, V]][]string{}
+
+	for _, k := range keys {
+		s := c.shard(k)
+		byShard[s] = append(byShard[s], k)
+	}
+
+	for s, shardKeys := range byShard {
+		if err := s.SetTombstones(ctx, shardKeys, modelVersion, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ShardedLRUCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	for _, k := range keys {
+		if err := c.shard(k.Key).Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ShardedLRUCache[T, V]) Clear(ctx context.Context) error {
+	for _, s := range c.shards {
+		if err := s.Clear(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ShardedLRUCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	return c.shard(key.Key).Exists(ctx, key)
+}
+
+// TTL returns remaining ttl of not expired entry, see LRUCache.TTL.
+func (c *ShardedLRUCache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	return c.shard(key.Key).TTL(ctx, key)
+}
+
+// Update runs fn under per-key lock of shard, see LRUCache.Update.
+func (c *ShardedLRUCache[T, V]) Update(
+	ctx context.Context,
+	key *Key[V],
+	modelVersion uint16,
+	ttl time.Duration,
+	fn func(current *T) (*T, error),
+) (*T, error) {
+	return c.shard(key.Key).Update(ctx, key, modelVersion, ttl, fn)
+}
+
+// DeleteByPrefix removes keys starting with prefix from every shard, see PrefixDeleter.
+func (c *ShardedLRUCache[T, V]) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+
+	var finalErr error
+	for _, s := range c.shards {
+		n, err := s.DeleteByPrefix(ctx, prefix)
+		removed += n
+
+		if err != nil {
+			finalErr = multierror.Append(finalErr, err)
+		}
+	}
+
+	return removed, finalErr
+}
+
+// Scan calls fn for not expired keys starting with prefix shard by shard, see Scanner.
+func (c *ShardedLRUCache[T, V]) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	stopped := false
+
+	for _, s := range c.shards {
+		err := s.Scan(ctx, prefix, func(key string) bool {
+			stopped = !fn(key)
+
+			return !stopped
+		})
+
+		if err != nil || stopped {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping always succeeds for in-memory cache, see Pinger.
+func (c *ShardedLRUCache[T, V]) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Len returns amount of stored entries across shards, see LRUCache.Len.
+func (c *ShardedLRUCache[T, V]) Len() int {
+	n := 0
+
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+
+	return n
+}
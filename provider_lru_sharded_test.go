@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedLRUCache(t *testing.T) {
+	currentModelVersion := uint16(7)
+	c := NewShardedLRUCache[EntityToCache, int](4, 400, time.Hour)
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 100; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+
+		if i%2 == 0 {
+			values[key.Key] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+		}
+	}
+
+	assert.Nil(t, c.MSet(context.TODO(), values, 0))
+	assert.Nil(t, c.SetTombstones(context.TODO(), []string{keys[1].Key}, currentModelVersion, 0))
+	assert.Equal(t, 51, c.Len())
+
+	for _, s := range c.shards {
+		assert.NotZero(t, s.Len()) // keys are spread over shards
+		assert.Equal(t, 100, s.Cap())
+	}
+
+	found, missing, err := c.MGet(context.TODO(), keys, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Len(t, found, 51)
+	assert.Nil(t, found[keys[1]])
+	assert.Len(t, missing, 49)
+	assert.Equal(t, keys[3], missing[0]) // order of keys is kept
+	assert.Equal(t, keys[99], missing[48])
+
+	v, err := c.Get(context.TODO(), keys[2], currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Id)
+
+	_, err = c.Get(context.TODO(), keys[1], currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	assert.Nil(t, c.Delete(context.TODO(), keys[2], keys[4]))
+	exists, err := c.Exists(context.TODO(), keys[2])
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	removed, err := c.DeleteByPrefix(context.TODO(), "entity:1")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, removed) // entity:1 tombstone, entity:10, 12, 14, 16, 18
+
+	scanned := 0
+	assert.Nil(t, c.Scan(context.TODO(), "", func(key string) bool {
+		scanned++
+		return scanned < 3
+	}))
+	assert.Equal(t, 3, scanned)
+
+	assert.Nil(t, c.Clear(context.TODO()))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestShardedLRUCacheEvictsPerShard(t *testing.T) {
+	c := NewShardedLRUCache[EntityToCache, int](2, 4, time.Hour)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{fmt.Sprint(i): {Id: i}}, 0))
+	}
+
+	assert.Equal(t, 4, c.Len())
+
+	for _, s := range c.shards {
+		assert.Equal(t, 2, s.Len())
+	}
+}
+
+func BenchmarkLRUCacheParallel(b *testing.B) {
+	currentModelVersion := uint16(7)
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 10000; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+	}
+
+	for name, provider := range map[string]Provider[EntityToCache, int]{
+		"single":  NewLRUCache[EntityToCache, int](len(keys), time.Hour),
+		"sharded": NewShardedLRUCache[EntityToCache, int](32, len(keys), time.Hour),
+	} {
+		b.Run(name, func(b *testing.B) {
+			if err := provider.MSet(context.TODO(), values, 0); err != nil {
+				b.Fatal(err)
+			}
+
+			var seed atomic.Int64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(seed.Add(7919))
+
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					i++
+
+					if i%10 == 0 {
+						_ = provider.MSet(context.TODO(), map[string]*EntityToCache{key.Key: values[key.Key]}, 0)
+						continue
+					}
+
+					if _, err := provider.Get(context.TODO(), key, currentModelVersion); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestFnv32a(t *testing.T) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("entity:1"))
+
+	assert.Equal(t, h.Sum32(), fnv32a("entity:1"))
+}
@@ -0,0 +1,467 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestLRUCachePerItemTtl(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+	lru.now = func() time.Time {
+		return now
+	}
+
+	shortKey := &Key[int]{Key: "short", OriginalValue: 1}
+	longKey := &Key[int]{Key: "long", OriginalValue: 2}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		shortKey.Key: {Id: 1, Value: "short", ModelVersion: currentModelVersion},
+	}, time.Second))
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		longKey.Key: {Id: 2, Value: "long", ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	now = now.Add(2 * time.Second)
+
+	found, missing, err := lru.MGet(context.TODO(), []*Key[int]{shortKey, longKey}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{shortKey}, missing)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, "long", found[longKey].Value)
+
+	now = now.Add(time.Minute)
+
+	v, err := lru.Get(context.TODO(), longKey, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheDefaultTtlOnZero(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Minute)
+	lru.now = func() time.Time {
+		return now
+	}
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "value", ModelVersion: currentModelVersion},
+	}, 0))
+
+	now = now.Add(59 * time.Second)
+
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v.Value)
+
+	now = now.Add(2 * time.Second)
+
+	v, err = lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](2, time.Hour)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	for _, k := range []*Key[int]{key1, key2} {
+		assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+			k.Key: {Id: k.OriginalValue, ModelVersion: currentModelVersion},
+		}, 0))
+	}
+
+	v, _ := lru.Get(context.TODO(), key1, currentModelVersion) // key2 becomes the oldest
+	assert.NotNil(t, v)
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key3.Key: {Id: key3.OriginalValue, ModelVersion: currentModelVersion},
+	}, 0))
+
+	found, missing, err := lru.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing)
+	assert.Equal(t, 2, len(found))
+}
+
+func TestLRUCacheDelete(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, 0))
+
+	assert.Nil(t, lru.Delete(context.TODO(), key1))
+
+	found, missing, err := lru.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key1}, missing)
+	assert.Equal(t, 2, found[key2].Id)
+}
+
+func TestLRUCacheClear(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	assert.Nil(t, lru.Clear(context.TODO()))
+
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheTombstoneRespectsModelVersion(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, lru.SetTombstones(context.TODO(), []string{key.Key}, currentModelVersion, time.Minute))
+
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+	assert.Nil(t, v)
+
+	found, missing, err := lru.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	tombstone, ok := found[key]
+	assert.True(t, ok)
+	assert.Nil(t, tombstone)
+
+	v, err = lru.Get(context.TODO(), key, currentModelVersion+1)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheSlidingExpiration(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour, WithSlidingExpiration(time.Minute))
+	lru.now = func() time.Time {
+		return now
+	}
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(50 * time.Second)
+
+		v, err := lru.Get(context.TODO(), key, currentModelVersion)
+		assert.Nil(t, err)
+		assert.NotNil(t, v)
+	}
+
+	now = now.Add(61 * time.Second)
+
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheSlidingExpirationTombstone(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour, WithSlidingExpiration(time.Minute))
+	lru.now = func() time.Time {
+		return now
+	}
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	assert.Nil(t, lru.SetTombstones(context.TODO(), []string{key.Key}, currentModelVersion, time.Minute))
+
+	now = now.Add(50 * time.Second)
+	_, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	now = now.Add(20 * time.Second)
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err) // tombstone expired despite read
+	assert.Nil(t, v)
+}
+
+func TestLRUCacheCancelledContext(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := lru.Get(ctx, key, currentModelVersion)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, _, err = lru.MGet(ctx, []*Key[int]{key}, currentModelVersion)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, lru.MSet(ctx, map[string]*EntityToCache{
+		key.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, 0), context.Canceled)
+
+	v, err := lru.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+}
+
+func TestLRUCacheExistsIgnoresModelVersion(t *testing.T) {
+	currentModelVersion := uint16(7)
+	now := time.Now()
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+	lru.now = func() time.Time {
+		return now
+	}
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	ok, err := lru.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion - 1},
+	}, time.Minute))
+
+	ok, err = lru.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Minute)
+
+	ok, err = lru.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestLRUCacheEvictsStaleVersions(t *testing.T) {
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+
+	for _, keep := range []bool{false, true} {
+		lru := NewLRUCache[EntityToCache, int](10, time.Hour, WithKeepStaleVersions(keep))
+
+		assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+			key.Key: {Id: 1, ModelVersion: 1},
+		}, 0))
+
+		v, err := lru.Get(context.TODO(), key, 2)
+		assert.Nil(t, err)
+		assert.Nil(t, v)
+
+		ok, err := lru.Exists(context.TODO(), key)
+		assert.Nil(t, err)
+		assert.Equal(t, keep, ok)
+	}
+}
+
+func TestLRUCacheLenAndCap(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](2, time.Hour)
+	assert.Equal(t, 2, lru.Cap())
+	assert.Equal(t, 0, lru.Len())
+
+	for i, key := range []string{"key1", "key2", "key3"} {
+		assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+			key: {Id: i, ModelVersion: currentModelVersion},
+		}, 0))
+	}
+
+	assert.Equal(t, 2, lru.Len())
+	assert.Equal(t, []string{"key3", "key2"}, lru.Entries())
+
+	assert.Nil(t, lru.Delete(context.TODO(), &Key[int]{Key: "key3"}))
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestLRUCacheEvictionCallback(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	var evicted []string
+	lru := NewLRUCache[EntityToCache, int](1, time.Hour).WithEvictionCallback(func(key string, value *EntityToCache) {
+		evicted = append(evicted, key)
+		assert.Equal(t, 1, value.Id)
+	})
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+	assert.Empty(t, evicted)
+
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		"key2": {Id: 2, ModelVersion: currentModelVersion},
+	}, 0))
+
+	assert.Equal(t, []string{"key1"}, evicted)
+}
+
+func TestLRUCacheScan(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		"user:1":  {Id: 1, ModelVersion: currentModelVersion},
+		"order:1": {Id: 2, ModelVersion: currentModelVersion},
+	}, 0))
+	assert.Nil(t, lru.MSet(context.TODO(), map[string]*EntityToCache{
+		"user:2": {Id: 3, ModelVersion: currentModelVersion},
+	}, 0))
+
+	var keys []string
+	assert.Nil(t, lru.Scan(context.TODO(), "user:", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.Equal(t, []string{"user:2", "user:1"}, keys)
+}
+
+func TestLRUCacheUpdate(t *testing.T) {
+	lru := NewLRUCache[EntityToCache, int](10, time.Hour)
+	key := &Key[int]{Key: "counter", OriginalValue: 1}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := lru.Update(context.TODO(), key, 7, 0, func(current *EntityToCache) (*EntityToCache, error) {
+				next := &EntityToCache{Id: 1, ModelVersion: 7}
+				if current != nil {
+					next.Id = current.Id + 1
+				}
+
+				return next, nil
+			})
+			assert.Nil(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	v, err := lru.Get(context.TODO(), key, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, 50, v.Id)
+	assert.Empty(t, lru.locks.locks)
+
+	v, err = lru.Update(context.TODO(), key, 7, 0, func(current *EntityToCache) (*EntityToCache, error) {
+		return nil, nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+	assert.Equal(t, 0, lru.Len())
+}
+
+func TestLRUCacheMemoryLimit(t *testing.T) {
+	sizeOf := func(value *EntityToCache) int64 {
+		return int64(len(value.Value))
+	}
+	c := NewLRUCacheWithMemoryLimit[EntityToCache, int](100, sizeOf, time.Hour)
+
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{
+			fmt.Sprint(i): {Id: i, Value: strings.Repeat("x", i*5)},
+		}, 0))
+
+		assert.LessOrEqual(t, c.Bytes(), int64(100))
+	}
+
+	assert.Equal(t, []string{"19"}, c.Entries()) // 2 bytes of key and 95 of value
+	assert.Equal(t, int64(97), c.Bytes())
+
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{"big": {Value: strings.Repeat("x", 100)}}, 0))
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, int64(0), c.Bytes())
+
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{"a": {Value: "xx"}, "b": {Value: "xxx"}}, 0))
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{"a": {Value: "x"}}, 0))
+	assert.Equal(t, int64(6), c.Bytes())
+
+	assert.Nil(t, c.Delete(context.TODO(), &Key[int]{Key: "a"}))
+	assert.Equal(t, int64(4), c.Bytes())
+}
+
+func TestLRUCacheMemoryLimitDefaultSize(t *testing.T) {
+	c := NewLRUCacheWithMemoryLimit[EntityToCache, int](1000, nil, time.Hour)
+
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{"a": {Id: 1, Value: "value"}}, 0))
+
+	b, err := msgpack.Marshal(&EntityToCache{Id: 1, Value: "value"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(b)+1), c.Bytes())
+}
+
+func TestLRUCacheSkipUnchangedWrites(t *testing.T) {
+	now := time.Now()
+	c := NewLRUCache[EntityToCache, int](10, time.Hour, WithSkipUnchangedWrites(true))
+	c.now = func() time.Time {
+		return now
+	}
+
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, Value: "a"},
+		"key2": {Id: 2, Value: "b"},
+	}, time.Minute))
+
+	now = now.Add(50 * time.Second)
+
+	assert.Nil(t, c.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, Value: "a"},
+		"key2": {Id: 2, Value: "changed"},
+	}, time.Minute))
+
+	now = now.Add(20 * time.Second)
+
+	v, err := c.Get(context.TODO(), &Key[int]{Key: "key1"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", v.Value) // ttl was reset by unchanged write
+
+	v, err = c.Get(context.TODO(), &Key[int]{Key: "key2"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "changed", v.Value)
+}
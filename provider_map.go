@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MapCache is in-memory provider without eviction, entries stay until Delete or Clear and ttl of values is ignored.
+// Tombstones expire after their ttl, so keys missing in source are looked up again once negative ttl passes.
+// Intended for small reference datasets as L1 in front of remote provider.
+type MapCache[T Entity, V any] struct {
+	items     sync.Map
+	keepStale bool
+	now       func() time.Time
+}
+
+type mapEntry[T any] struct {
+	value        *T
+	tombstone    bool
+	modelVersion uint16
+	expiresAt    time.Time // of tombstone, zero for entries which never expire
+}
+
+// NewMapCache creates map provider, only WithKeepStaleVersions is applicable from provider options.
+func NewMapCache[T Entity, V any](opts ...ProviderOption) *MapCache[T, V] {
+	o := newProviderOptions(opts...)
+
+	return &MapCache[T, V]{
+		keepStale: o.keepStale,
+		now:       time.Now,
+	}
+}
+
+func (c *MapCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	item, tombstone := c.get(key.Key, requiredModelVersion)
+	if tombstone {
+		return nil, ErrTombstone
+	}
+
+	return item, nil
+}
+
+func (c *MapCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var missing []*Key[V]
+	results := map[*Key[V]]*T{}
+
+	for _, key := range keys {
+		item, tombstone := c.get(key.Key, requiredModelVersion)
+
+		if item == nil && !tombstone {
+			missing = append(missing, key)
+			continue
+		}
+
+		results[key] = item
+	}
+
+	return results, missing, nil
+}
+
+// TTL returns TTLNoExpiry for stored value, as MapCache ignores ttl of values, and remaining ttl of tombstone,
+// see TTLReader.
+func (c *MapCache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return TTLNotFound, err
+	}
+
+	entry, ok := c.load(key.Key)
+	switch {
+	case !ok:
+		return TTLNotFound, nil
+	case entry.expiresAt.IsZero():
+		return TTLNoExpiry, nil
+	default:
+		return entry.expiresAt.Sub(c.now()), nil
+	}
+}
+
+func (c *MapCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	_, ok := c.load(key.Key)
+
+	return ok, nil
+}
+
+func (c *MapCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_ = ttl
+
+	for k, v := range values {
+		c.items.Store(k, &mapEntry[T]{value: v})
+	}
+
+	return nil
+}
+
+// SetTombstones stores negative entries expiring after ttl, unlike values. Non-positive ttl never expires.
+func (c *MapCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	for _, k := range keys {
+		c.items.Store(k, &mapEntry[T]{tombstone: true, modelVersion: modelVersion, expiresAt: expiresAt})
+	}
+
+	return nil
+}
+
+func (c *MapCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		c.items.Delete(key.Key)
+	}
+
+	return nil
+}
+
+func (c *MapCache[T, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.items.Range(func(key, _ any) bool {
+		c.items.Delete(key)
+		return true
+	})
+
+	return nil
+}
+
+// Len returns amount of stored entries, including ones with stale model version and expired tombstones not read since.
+func (c *MapCache[T, V]) Len() int {
+	count := 0
+
+	c.items.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+
+	return count
+}
+
+func (c *MapCache[T, V]) get(key string, requiredModelVersion uint16) (*T, bool) {
+	entry, ok := c.load(key)
+	if !ok {
+		return nil, false
+	}
+
+	if entry.tombstone {
+		if !versionMatches(entry.modelVersion, requiredModelVersion) {
+			c.removeStale(key, entry)
+			return nil, false
+		}
+
+		return nil, true
+	}
+
+	if entry.value == nil || !versionMatches((*entry.value).GetCacheModelVersion(), requiredModelVersion) {
+		c.removeStale(key, entry)
+		return nil, false
+	}
+
+	return entry.value, false
+}
+
+// load returns stored entry, expired tombstone is deleted unless it was replaced concurrently.
+func (c *MapCache[T, V]) load(key string) (*mapEntry[T], bool) {
+	v, ok := c.items.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry := v.(*mapEntry[T])
+
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.items.CompareAndDelete(key, entry)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// removeStale deletes entry only if it was not replaced concurrently.
+func (c *MapCache[T, V]) removeStale(key string, entry *mapEntry[T]) {
+	if !c.keepStale {
+		c.items.CompareAndDelete(key, entry)
+	}
+}
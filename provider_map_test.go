@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapCache(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Nanosecond))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, currentModelVersion, time.Minute))
+	assert.Equal(t, 3, provider.Len())
+
+	time.Sleep(time.Millisecond) // ttl of values is ignored
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, 1, found[key1].Id)
+	assert.Equal(t, 2, found[key2].Id)
+	assert.Nil(t, found[key3])
+
+	_, err = provider.Get(context.TODO(), key3, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	assert.Nil(t, provider.Delete(context.TODO(), key1))
+	assert.Equal(t, 2, provider.Len())
+
+	assert.Nil(t, provider.Clear(context.TODO()))
+	assert.Equal(t, 0, provider.Len())
+}
+
+func TestMapCacheTombstoneExpiry(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key1.Key}, currentModelVersion, time.Minute))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key2.Key}, currentModelVersion, 0))
+
+	ttl, err := provider.TTL(context.TODO(), key1)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	now = now.Add(time.Minute)
+
+	exists, err := provider.Exists(context.TODO(), key1)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	_, err = provider.Get(context.TODO(), key2, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone) // tombstone without ttl never expires
+
+	assert.Equal(t, 1, provider.Len())
+}
+
+func TestMapCacheModelVersionBump(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	provider := NewMapCache[EntityToCache, int]()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key2.Key}, currentModelVersion, 0))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion+1)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion+1)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, []*Key[int]{key1, key2}, missing)
+	assert.Equal(t, 0, provider.Len())
+}
+
+func TestMapCacheContextCanceled(t *testing.T) {
+	provider := NewMapCache[EntityToCache, int]()
+	key := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := provider.Get(ctx, key, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, _, err = provider.MGet(ctx, []*Key[int]{key}, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, provider.MSet(ctx, map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: 1},
+	}, 0), context.Canceled)
+	assert.Equal(t, 0, provider.Len())
+}
@@ -0,0 +1,350 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// memcachedMaxRelativeTtl is the longest expiration memcached treats as relative,
+// larger values are interpreted as unix timestamp.
+const memcachedMaxRelativeTtl = 30 * 24 * time.Hour
+
+// memcachedMaxKeyLength is the longest key memcached accepts.
+const memcachedMaxKeyLength = 250
+
+// MemcachedClient is the subset of *memcache.Client used by MemcachedCache.
+type MemcachedClient interface {
+	Get(key string) (*memcache.Item, error)
+	GetMulti(keys []string) (map[string]*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+}
+
+type MemcachedCache[T Entity, V any] struct {
+	client      MemcachedClient
+	chunkSize   int
+	keyPrefix   string
+	codec       Codec
+	compression CompressionAlgorithm
+	logger      Logger
+	selfHeal    bool
+	keepStale   bool
+	now         func() time.Time
+}
+
+// NewMemcachedCache creates provider backed by memcached.
+// WithKeyPrefix, WithCodec, WithCompression, WithChunkSize, WithLogger, WithSlog, WithSelfHealCorruptEntries
+// and WithKeepStaleVersions are applicable from provider options.
+func NewMemcachedCache[T Entity, V any](
+	client MemcachedClient,
+	opts ...ProviderOption,
+) *MemcachedCache[T, V] {
+	o := newProviderOptions(opts...)
+
+	return &MemcachedCache[T, V]{
+		client:      client,
+		chunkSize:   o.chunkSize,
+		keyPrefix:   o.keyPrefix,
+		codec:       o.codec,
+		compression: o.compression,
+		logger:      o.logger,
+		selfHeal:    o.selfHeal,
+		keepStale:   o.keepStale,
+		now:         time.Now,
+	}
+}
+
+// Get reports entry which can not be decoded as missing and logs it at Warn, as MGet does,
+// so only memcached failures are returned.
+func (m *MemcachedCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	memcachedKey, err := m.memcachedKey(key.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := m.client.Get(memcachedKey)
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return nil, nil
+		}
+
+		return nil, errors.WithStack(err)
+	}
+
+	v, err := decodeEntity[T](m.codec, item.Value, requiredModelVersion)
+	if m.shouldDrop(err) {
+		m.drop(item.Key)
+	}
+
+	switch {
+	case errors.Is(err, ErrTombstone):
+		return nil, err
+	case errors.Is(err, errStaleVersion):
+		return nil, nil
+	case err != nil: // corrupt entry is a miss as in MGet, only backend failures are returned
+		m.logger.Warn(err, "can not decode cached value", keyCount(1))
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// Ping checks memcached connectivity when client supports it, e.g. *memcache.Client, see Pinger.
+func (m *MemcachedCache[T, V]) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if pinger, ok := m.client.(interface{ Ping() error }); ok {
+		return errors.WithStack(pinger.Ping())
+	}
+
+	return nil
+}
+
+// Exists fetches raw item, as memcached has no dedicated command, but skips decoding.
+func (m *MemcachedCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	memcachedKey, err := m.memcachedKey(key.Key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := m.client.Get(memcachedKey); err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return false, nil
+		}
+
+		return false, errors.WithStack(err)
+	}
+
+	return true, nil
+}
+
+func (m *MemcachedCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	var missing []*Key[V]
+	results := map[*Key[V]]*T{}
+
+	for _, chunk := range chunkBy(keys, m.chunkSize) {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		validKeys := make([]*Key[V], 0, len(chunk))
+		strSlice := make([]string, 0, len(chunk))
+
+		for _, v := range chunk {
+			memcachedKey, err := m.memcachedKey(v.Key)
+			if err != nil {
+				m.logger.Warn(err, "can not get value from memcached", keyCount(1))
+				missing = append(missing, v)
+				continue
+			}
+
+			validKeys = append(validKeys, v)
+			strSlice = append(strSlice, memcachedKey)
+		}
+
+		if len(strSlice) == 0 {
+			continue
+		}
+
+		items, err := m.client.GetMulti(strSlice)
+		if err != nil {
+			m.logger.Error(err, "can not get chunk from memcached", keyCount(len(strSlice)))
+			missing = append(missing, validKeys...)
+			continue
+		}
+
+		for i, key := range validKeys {
+			item, ok := items[strSlice[i]]
+			if !ok {
+				missing = append(missing, key)
+				continue
+			}
+
+			v, err := decodeEntity[T](m.codec, item.Value, requiredModelVersion)
+
+			if m.shouldDrop(err) {
+				m.drop(item.Key)
+			}
+
+			if errors.Is(err, ErrTombstone) {
+				results[key] = nil
+				continue
+			}
+
+			if errors.Is(err, errStaleVersion) {
+				missing = append(missing, key)
+				continue
+			}
+
+			if err != nil {
+				m.logger.Warn(err, "can not decode cached value", keyCount(1))
+				missing = append(missing, key)
+				continue
+			}
+
+			results[key] = v
+		}
+	}
+
+	return results, missing, nil
+}
+
+// MSet returns *FailedKeysError when part of values can not be encoded or written.
+func (m *MemcachedCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var multiErr error
+	var failed []string
+	expiration := m.expiration(ttl)
+
+	for k, v := range values {
+		memcachedKey, err := m.memcachedKey(k)
+
+		var b []byte
+		if err == nil {
+			b, err = encodeEntity(m.codec, m.compression, v)
+		}
+
+		if err == nil {
+			err = errors.WithStack(m.client.Set(&memcache.Item{
+				Key:        memcachedKey,
+				Value:      b,
+				Expiration: expiration,
+			}))
+		}
+
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			failed = append(failed, k)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &FailedKeysError{Keys: failed, Err: multiErr}
+	}
+
+	return nil
+}
+
+func (m *MemcachedCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var multiErr error
+	tombstone := encodeTombstone(modelVersion)
+	expiration := m.expiration(ttl)
+
+	for _, k := range keys {
+		memcachedKey, err := m.memcachedKey(k)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+
+		if err := m.client.Set(&memcache.Item{
+			Key:        memcachedKey,
+			Value:      tombstone,
+			Expiration: expiration,
+		}); err != nil {
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+		}
+	}
+
+	return multiErr
+}
+
+func (m *MemcachedCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var multiErr error
+
+	for _, key := range keys {
+		memcachedKey, err := m.memcachedKey(key.Key)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+
+		if err := m.client.Delete(memcachedKey); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+		}
+	}
+
+	return multiErr
+}
+
+// Clear is not supported, as memcached can not enumerate keys and flushing may affect other tenants of the server.
+// Bump model version to invalidate entries instead, ErrClearNotSupported is returned.
+func (m *MemcachedCache[T, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return errors.WithStack(ErrClearNotSupported)
+}
+
+// shouldDrop reports whether entry with given decode error should be deleted,
+// see WithSelfHealCorruptEntries and WithKeepStaleVersions.
+func (m *MemcachedCache[T, V]) shouldDrop(decodeErr error) bool {
+	switch {
+	case decodeErr == nil, errors.Is(decodeErr, ErrTombstone):
+		return false
+	case errors.Is(decodeErr, errStaleVersion):
+		return !m.keepStale
+	default:
+		return m.selfHeal
+	}
+}
+
+// drop is best-effort deletion of entry found invalid on read.
+func (m *MemcachedCache[T, V]) drop(key string) {
+	if err := m.client.Delete(key); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		m.logger.Error(err, "can not delete invalid value", keyCount(1))
+	}
+}
+
+// memcachedKey returns prefixed key, *KeyTooLongError is returned for key memcached would reject.
+func (m *MemcachedCache[T, V]) memcachedKey(key string) (string, error) {
+	memcachedKey := m.keyPrefix + key
+	if len(memcachedKey) > memcachedMaxKeyLength {
+		return "", errors.WithStack(&KeyTooLongError{Key: memcachedKey, MaxLength: memcachedMaxKeyLength})
+	}
+
+	return memcachedKey, nil
+}
+
+func (m *MemcachedCache[T, V]) expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	if ttl > memcachedMaxRelativeTtl {
+		return int32(m.now().Add(ttl).Unix())
+	}
+
+	seconds := int32(ttl / time.Second)
+	if seconds == 0 {
+		seconds = 1
+	}
+
+	return seconds
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMemcached struct {
+	mut           sync.Mutex
+	items         map[string]*memcache.Item
+	getMultiCalls int
+	getMultiErr   error
+}
+
+func newFakeMemcached() *fakeMemcached {
+	return &fakeMemcached{
+		items: map[string]*memcache.Item{},
+	}
+}
+
+func (f *fakeMemcached) Get(key string) (*memcache.Item, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	item, ok := f.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+
+	return item, nil
+}
+
+func (f *fakeMemcached) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	f.getMultiCalls++
+	if f.getMultiErr != nil {
+		return nil, f.getMultiErr
+	}
+
+	result := map[string]*memcache.Item{}
+
+	for _, k := range keys {
+		if item, ok := f.items[k]; ok {
+			result[k] = item
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeMemcached) Set(item *memcache.Item) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	f.items[item.Key] = item
+
+	return nil
+}
+
+func (f *fakeMemcached) Delete(key string) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if _, ok := f.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+
+	delete(f.items, key)
+
+	return nil
+}
+
+func TestMemcachedCache(t *testing.T) {
+	currentModelVersion := uint16(7)
+	client := newFakeMemcached()
+
+	provider := NewMemcachedCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithChunkSize(2))
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "entity:3", OriginalValue: 3}
+	staleKey := &Key[int]{Key: "entity:4", OriginalValue: 4}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key:     {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key:     {Id: 2, ModelVersion: currentModelVersion},
+		staleKey.Key: {Id: 4, ModelVersion: currentModelVersion - 1},
+	}, time.Minute))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, currentModelVersion, time.Minute))
+
+	assert.Equal(t, int32(60), client.items["app:entity:1"].Expiration)
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+
+	_, err = provider.Get(context.TODO(), key3, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2, key3, staleKey}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.getMultiCalls)
+	assert.Equal(t, []*Key[int]{staleKey}, missing)
+	assert.Equal(t, 3, len(found))
+	assert.Equal(t, 2, found[key2].Id)
+	assert.Nil(t, found[key3])
+
+	assert.Nil(t, provider.Delete(context.TODO(), key1, &Key[int]{Key: "unknown"}))
+
+	v, err = provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	assert.ErrorIs(t, provider.Clear(context.TODO()), ErrClearNotSupported)
+}
+
+func TestMemcachedCacheKeyTooLong(t *testing.T) {
+	currentModelVersion := uint16(7)
+	client := newFakeMemcached()
+	logger := &recordingLogger{}
+
+	provider := NewMemcachedCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithLogger(logger))
+
+	longKey := &Key[int]{Key: strings.Repeat("k", 247), OriginalValue: 1}
+	key := &Key[int]{Key: strings.Repeat("k", 246), OriginalValue: 2}
+
+	var keyErr *KeyTooLongError
+
+	err := provider.MSet(context.TODO(), map[string]*EntityToCache{
+		longKey.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key.Key:     {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Minute)
+	assert.ErrorAs(t, err, &keyErr)
+	assert.Equal(t, 251, len(keyErr.Key))
+	assert.Equal(t, 250, keyErr.MaxLength)
+
+	var failed *FailedKeysError
+	assert.ErrorAs(t, err, &failed)
+	assert.Equal(t, []string{longKey.Key}, failed.Keys)
+
+	_, err = provider.Get(context.TODO(), longKey, currentModelVersion)
+	assert.ErrorAs(t, err, &keyErr)
+
+	_, err = provider.Exists(context.TODO(), longKey)
+	assert.ErrorAs(t, err, &keyErr)
+
+	assert.ErrorAs(t, provider.SetTombstones(context.TODO(), []string{longKey.Key}, currentModelVersion, time.Minute), &keyErr)
+	assert.ErrorAs(t, provider.Delete(context.TODO(), longKey), &keyErr)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{longKey, key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{longKey}, missing)
+	assert.Equal(t, 2, found[key].Id)
+	assert.Equal(t, []string{"can not get value from memcached"}, logger.msgs)
+}
+
+func TestMemcachedCacheMGetClientError(t *testing.T) {
+	client := newFakeMemcached()
+	client.getMultiErr = errors.New("server is down")
+	logger := &recordingLogger{}
+
+	provider := NewMemcachedCache[EntityToCache, int](client, WithLogger(logger))
+	keys := []*Key[int]{{Key: "key1", OriginalValue: 1}, {Key: "key2", OriginalValue: 2}}
+
+	found, missing, err := provider.MGet(context.TODO(), keys, 7)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, keys, missing)
+	assert.Equal(t, []string{"can not get chunk from memcached"}, logger.msgs)
+	assert.Equal(t, []error{client.getMultiErr}, logger.errs)
+}
+
+func TestMemcachedCacheLongTtlIsAbsolute(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	provider := NewMemcachedCache[EntityToCache, int](newFakeMemcached())
+	provider.now = func() time.Time {
+		return now
+	}
+
+	assert.Equal(t, int32(0), provider.expiration(0))
+	assert.Equal(t, int32(1), provider.expiration(time.Millisecond))
+	assert.Equal(t, int32(now.Add(60*24*time.Hour).Unix()), provider.expiration(60*24*time.Hour))
+}
+
+func TestMemcachedCacheSelfHealCorruptEntries(t *testing.T) {
+	currentModelVersion := uint16(7)
+	client := newFakeMemcached()
+
+	provider := NewMemcachedCache[EntityToCache, int](client)
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	assert.Nil(t, client.Set(&memcache.Item{Key: key.Key, Value: []byte("not msgpack")}))
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key}, missing)
+	assert.Empty(t, client.items)
+}
+
+func TestMemcachedCacheGetCorruptEntry(t *testing.T) {
+	currentModelVersion := uint16(7)
+	client := newFakeMemcached()
+	logger := &recordingLogger{}
+
+	provider := NewMemcachedCache[EntityToCache, int](client, WithLogger(logger), WithSelfHealCorruptEntries(false))
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	assert.Nil(t, client.Set(&memcache.Item{Key: key.Key, Value: []byte("not msgpack")}))
+
+	v, err := provider.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err) // corrupt entry is a miss
+	assert.Nil(t, v)
+	assert.Equal(t, []slog.Level{slog.LevelWarn}, logger.levels)
+}
+
+func TestMemcachedCacheContextCanceled(t *testing.T) {
+	client := newFakeMemcached()
+	provider := NewMemcachedCache[EntityToCache, int](client)
+	key := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := provider.Get(ctx, key, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, _, err = provider.MGet(ctx, []*Key[int]{key}, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, client.getMultiCalls)
+
+	assert.ErrorIs(t, provider.MSet(ctx, map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: 1},
+	}, time.Minute), context.Canceled)
+	assert.Empty(t, client.items)
+}
@@ -22,6 +22,140 @@ func (_m *mockProvider[T, V]) EXPECT() *mockProvider_Expecter[T, V] {
 	return &mockProvider_Expecter[T, V]{mock: &_m.Mock}
 }
 
+// Clear provides a mock function with given fields: ctx
+func (_m *mockProvider[T, V]) Clear(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockProvider_Clear_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Clear'
+type mockProvider_Clear_Call[T interface{}, V interface{}] struct {
+	*mock.Call
+}
+
+// Clear is a helper method to define mock.On call
+//  - ctx context.Context
+func (_e *mockProvider_Expecter[T, V]) Clear(ctx interface{}) *mockProvider_Clear_Call[T, V] {
+	return &mockProvider_Clear_Call[T, V]{Call: _e.mock.On("Clear", ctx)}
+}
+
+func (_c *mockProvider_Clear_Call[T, V]) Run(run func(ctx context.Context)) *mockProvider_Clear_Call[T, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockProvider_Clear_Call[T, V]) Return(_a0 error) *mockProvider_Clear_Call[T, V] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+// Delete provides a mock function with given fields: ctx, keys
+func (_m *mockProvider[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	_va := make([]interface{}, len(keys))
+	for _i := range keys {
+		_va[_i] = keys[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, ...*Key[V]) error); ok {
+		r0 = rf(ctx, keys...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockProvider_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type mockProvider_Delete_Call[T interface{}, V interface{}] struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//  - ctx context.Context
+//  - keys ...*Key[V]
+func (_e *mockProvider_Expecter[T, V]) Delete(ctx interface{}, keys ...interface{}) *mockProvider_Delete_Call[T, V] {
+	return &mockProvider_Delete_Call[T, V]{Call: _e.mock.On("Delete",
+		append([]interface{}{ctx}, keys...)...)}
+}
+
+func (_c *mockProvider_Delete_Call[T, V]) Run(run func(ctx context.Context, keys ...*Key[V])) *mockProvider_Delete_Call[T, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]*Key[V], len(args)-1)
+		for i, a := range args[1:] {
+			if a != nil {
+				variadicArgs[i] = a.(*Key[V])
+			}
+		}
+		run(args[0].(context.Context), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *mockProvider_Delete_Call[T, V]) Return(_a0 error) *mockProvider_Delete_Call[T, V] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+// Exists provides a mock function with given fields: ctx, key
+func (_m *mockProvider[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *Key[V]) bool); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *Key[V]) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// mockProvider_Exists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exists'
+type mockProvider_Exists_Call[T interface{}, V interface{}] struct {
+	*mock.Call
+}
+
+// Exists is a helper method to define mock.On call
+//  - ctx context.Context
+//  - key *Key[V]
+func (_e *mockProvider_Expecter[T, V]) Exists(ctx interface{}, key interface{}) *mockProvider_Exists_Call[T, V] {
+	return &mockProvider_Exists_Call[T, V]{Call: _e.mock.On("Exists", ctx, key)}
+}
+
+func (_c *mockProvider_Exists_Call[T, V]) Run(run func(ctx context.Context, key *Key[V])) *mockProvider_Exists_Call[T, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*Key[V]))
+	})
+	return _c
+}
+
+func (_c *mockProvider_Exists_Call[T, V]) Return(_a0 bool, _a1 error) *mockProvider_Exists_Call[T, V] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // Get provides a mock function with given fields: ctx, key, requiredModelVersion
 func (_m *mockProvider[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
 	ret := _m.Called(ctx, key, requiredModelVersion)
@@ -166,6 +300,46 @@ func (_c *mockProvider_MSet_Call[T, V]) Return(_a0 error) *mockProvider_MSet_Cal
 	return _c
 }
 
+// SetTombstones provides a mock function with given fields: ctx, keys, modelVersion, ttl
+func (_m *mockProvider[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	ret := _m.Called(ctx, keys, modelVersion, ttl)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, uint16, time.Duration) error); ok {
+		r0 = rf(ctx, keys, modelVersion, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockProvider_SetTombstones_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetTombstones'
+type mockProvider_SetTombstones_Call[T interface{}, V interface{}] struct {
+	*mock.Call
+}
+
+// SetTombstones is a helper method to define mock.On call
+//  - ctx context.Context
+//  - keys []string
+//  - modelVersion uint16
+//  - ttl time.Duration
+func (_e *mockProvider_Expecter[T, V]) SetTombstones(ctx interface{}, keys interface{}, modelVersion interface{}, ttl interface{}) *mockProvider_SetTombstones_Call[T, V] {
+	return &mockProvider_SetTombstones_Call[T, V]{Call: _e.mock.On("SetTombstones", ctx, keys, modelVersion, ttl)}
+}
+
+func (_c *mockProvider_SetTombstones_Call[T, V]) Run(run func(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration)) *mockProvider_SetTombstones_Call[T, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]string), args[2].(uint16), args[3].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *mockProvider_SetTombstones_Call[T, V]) Return(_a0 error) *mockProvider_SetTombstones_Call[T, V] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
 type mockConstructorTestingTnewMockProvider interface {
 	mock.TestingT
 	Cleanup(func())
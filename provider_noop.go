@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// NoopCache is provider which never stores anything: every read misses and writes are dropped.
+// Useful to disable caching in tests or by feature flag while keeping Cache wiring, so every read goes to source.
+type NoopCache[T Entity, V any] struct {
+}
+
+// NewNoopCache creates provider which always misses and drops writes.
+func NewNoopCache[T Entity, V any]() Provider[T, V] {
+	return &NoopCache[T, V]{}
+}
+
+func (n *NoopCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	return nil, nil
+}
+
+func (n *NoopCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	return map[*Key[V]]*T{}, keys, nil
+}
+
+func (n *NoopCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	return false, nil
+}
+
+func (n *NoopCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	return nil
+}
+
+func (n *NoopCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	return nil
+}
+
+func (n *NoopCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	return nil
+}
+
+func (n *NoopCache[T, V]) Clear(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoopCacheAlwaysQueriesSource(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	key := &Key[int]{Key: "key", OriginalValue: 1}
+	sourceCalls := 0
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, NewNoopCache[EntityToCache, int]()).
+		WithSyncWriteback(true).
+		WithNegativeCaching(time.Minute).
+		Build()
+
+	for i := 0; i < 2; i++ {
+		resp, err := ch.MGet(context.TODO(), []*Key[int]{key}, func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*EntityToCache, error) {
+			sourceCalls++
+			assert.Equal(t, []*Key[int]{key}, keys)
+
+			return map[*Key[int]]*EntityToCache{
+				key: {Id: key.OriginalValue, ModelVersion: currentModelVersion},
+			}, nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp[key].Id)
+	}
+
+	assert.Equal(t, 2, sourceCalls)
+
+	ok, err := ch.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+const DefaultChunkSize = 100
+
+type ProviderOption func(o *providerOptions)
+
+type providerOptions struct {
+	keyPrefix      string
+	codec          Codec
+	compression    CompressionAlgorithm
+	chunkSize      int
+	clusterMode    bool
+	slidingTtl     time.Duration
+	logger         Logger
+	selfHeal       bool
+	keepStale      bool
+	retries        int
+	backoff        time.Duration
+	maxConcurrency int
+	cbFailures     int
+	cbCooldown     time.Duration
+	versionedKeys  bool
+	readYourWrites time.Duration
+	skipUnchanged  bool
+	onCorruption   func(key string, raw []byte, err error)
+}
+
+func newProviderOptions(opts ...ProviderOption) *providerOptions {
+	o := &providerOptions{
+		codec:          MsgpackCodec{},
+		chunkSize:      DefaultChunkSize,
+		logger:         NewSlogLogger(nil),
+		selfHeal:       true,
+		maxConcurrency: defaultMaxConcurrency(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithKeyPrefix transparently prepends prefix to every key written and read by provider,
+// so instances sharing one redis do not collide. Clear removes only keys inside the prefix.
+func WithKeyPrefix(prefix string) ProviderOption {
+	return func(o *providerOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithCodec overrides serialization of stored entities, MsgpackCodec is used by default.
+func WithCodec(codec Codec) ProviderOption {
+	return func(o *providerOptions) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithCompression compresses encoded entities, entries written without compression are still readable.
+func WithCompression(algorithm CompressionAlgorithm) ProviderOption {
+	return func(o *providerOptions) {
+		o.compression = algorithm
+	}
+}
+
+// WithChunkSize sets amount of keys sent in one batch, DefaultChunkSize is used for non positive values.
+func WithChunkSize(size int) ProviderOption {
+	return func(o *providerOptions) {
+		if size <= 0 {
+			size = DefaultChunkSize
+		}
+
+		o.chunkSize = size
+	}
+}
+
+// WithMaxConcurrency bounds amount of chunks processed at once by single MGet,
+// runtime.NumCPU()*2 is used by default and for non positive values.
+func WithMaxConcurrency(n int) ProviderOption {
+	return func(o *providerOptions) {
+		if n <= 0 {
+			n = defaultMaxConcurrency()
+		}
+
+		o.maxConcurrency = n
+	}
+}
+
+func defaultMaxConcurrency() int {
+	return runtime.NumCPU() * 2
+}
+
+// WithClusterMode replaces multi key commands with pipelined single key ones, so keys from different slots
+// do not fail with CROSSSLOT. It is enabled automatically for *redis.ClusterClient.
+func WithClusterMode(enabled bool) ProviderOption {
+	return func(o *providerOptions) {
+		o.clusterMode = enabled
+	}
+}
+
+// WithSlidingExpiration resets entry expiry to ttl on every read, negatively cached keys expire as written.
+// Off by default as it changes eviction semantics: a key that is never idle for ttl never expires
+// and is refreshed only by explicit MSet or Delete. RedisCache reads with GETEX PX, available since Redis 6.2.
+func WithSlidingExpiration(ttl time.Duration) ProviderOption {
+	return func(o *providerOptions) {
+		o.slidingTtl = ttl
+	}
+}
+
+// WithRetry retries failed chunk reads and writes up to attempts times, doubling backoff after each attempt.
+// Only transient errors are retried, e.g. connection failures or MOVED and READONLY replies during failover.
+func WithRetry(attempts int, backoff time.Duration) ProviderOption {
+	return func(o *providerOptions) {
+		o.retries = attempts
+		o.backoff = backoff
+	}
+}
+
+// WithCircuitBreaker makes provider treat backend as down after failures consecutive errors: for cooldown reads
+// miss instantly, writes and deletes, including Clear and DeleteByPrefix, fail with ErrCircuitOpen,
+// then calls are let through again to probe it. Cache skips writing back to provider with open breaker,
+// so reads during outage are not followed by failing writes. Disabled by default.
+func WithCircuitBreaker(failures int, cooldown time.Duration) ProviderOption {
+	return func(o *providerOptions) {
+		o.cbFailures = failures
+		o.cbCooldown = cooldown
+	}
+}
+
+// WithVersionedKeys stores entries under keys including model version, e.g. v7:entity:1, so entries of old
+// version are never read after version bump and expire on their own. Written model versions are recorded
+// in model-versions set inside key prefix, so Delete, Exists and TTL address key in every version regardless
+// of provider instance. Counters of Incrementer are not versioned.
+func WithVersionedKeys(enabled bool) ProviderOption {
+	return func(o *providerOptions) {
+		o.versionedKeys = enabled
+	}
+}
+
+// WithReadYourWrites makes NewRedisCacheWithReplica read keys written through the provider within window
+// from primary, so replication lag does not hide them. Only writes of this provider instance are tracked.
+func WithReadYourWrites(window time.Duration) ProviderOption {
+	return func(o *providerOptions) {
+		o.readYourWrites = window
+	}
+}
+
+// WithSkipUnchangedWrites skips MSet of values equal to stored ones, so backend is not written, only their ttl
+// is reset. LRUCache compares values with reflect.DeepEqual. RedisCache compares encoded bytes, which costs
+// extra MGET round trip per chunk before every write and resets ttl of unchanged values with PEXPIRE,
+// so it pays off only when most written values are unchanged. Negatively cached keys are always overwritten.
+func WithSkipUnchangedWrites(enabled bool) ProviderOption {
+	return func(o *providerOptions) {
+		o.skipUnchanged = enabled
+	}
+}
+
+// WithLogger sets logger for provider errors which are not returned to caller, slog.Default is used by default.
+func WithLogger(logger Logger) ProviderOption {
+	return func(o *providerOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithSlog routes provider logging into logger.
+func WithSlog(logger *slog.Logger) ProviderOption {
+	return WithLogger(NewSlogLogger(logger))
+}
+
+// WithSelfHealCorruptEntries deletes entries which can not be decoded on read, so they are refetched from source
+// once instead of on every read until ttl. Enabled by default, deletion is best-effort and only logged on failure.
+func WithSelfHealCorruptEntries(enabled bool) ProviderOption {
+	return func(o *providerOptions) {
+		o.selfHeal = enabled
+	}
+}
+
+// WithCorruptionHandler calls fn with key, stored bytes and decode error of every entry RedisCache or RedisHashCache
+// can not decode, e.g. to keep them for analysis. Hash fields are passed encoded as JSON object. fn is called
+// synchronously before entry is deleted by WithSelfHealCorruptEntries, by default corruption is only logged.
+func WithCorruptionHandler(fn func(key string, raw []byte, err error)) ProviderOption {
+	return func(o *providerOptions) {
+		o.onCorruption = fn
+	}
+}
+
+// WithKeepStaleVersions disables deletion of entries with other model version found on read.
+// By default such entries are deleted, so model version bump reclaims space as keys are touched.
+func WithKeepStaleVersions(keep bool) ProviderOption {
+	return func(o *providerOptions) {
+		o.keepStale = keep
+	}
+}
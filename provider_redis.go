@@ -2,78 +2,529 @@ package cache
 
 import (
 	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
 type RedisCache[T Entity, V any] struct {
-	client    redis.Cmdable
-	chunkSize int
+	client         redis.Cmdable
+	replica        redis.Cmdable
+	recent         *recentKeys
+	chunkSize      int
+	keyPrefix      string
+	codec          Codec
+	compression    CompressionAlgorithm
+	clusterMode    bool
+	slidingTtl     time.Duration
+	logger         Logger
+	selfHeal       bool
+	keepStale      bool
+	retries        int
+	backoff        time.Duration
+	maxConcurrency int
+	breaker        *circuitBreaker
+	versionedKeys  bool
+	skipUnchanged  bool
+	onCorruption   func(key string, raw []byte, err error)
 }
 
+// versionsKeyName is name of versionsKey inside key prefix, it never collides with versioned keys starting with v.
+const versionsKeyName = "model-versions"
+
 func NewRedisCache[T Entity, V any](
 	client redis.Cmdable,
+	opts ...ProviderOption,
 ) Provider[T, V] {
+	o := newProviderOptions(opts...)
+
+	_, isCluster := client.(*redis.ClusterClient)
+
 	return &RedisCache[T, V]{
-		client:    client,
-		chunkSize: 100,
+		client:         client,
+		chunkSize:      o.chunkSize,
+		keyPrefix:      o.keyPrefix,
+		codec:          o.codec,
+		compression:    o.compression,
+		clusterMode:    isCluster || o.clusterMode,
+		slidingTtl:     o.slidingTtl,
+		logger:         o.logger,
+		selfHeal:       o.selfHeal,
+		keepStale:      o.keepStale,
+		retries:        o.retries,
+		backoff:        o.backoff,
+		maxConcurrency: o.maxConcurrency,
+		breaker:        newCircuitBreaker(o.cbFailures, o.cbCooldown),
+		versionedKeys:  o.versionedKeys,
+		skipUnchanged:  o.skipUnchanged,
+		onCorruption:   o.onCorruption,
+		recent:         newRecentKeys(o.readYourWrites),
+	}
+}
+
+// Client returns underlying redis client for commands provider does not wrap, e.g. TTL for diagnostics.
+// Keys are stored with configured key prefix, and model version with WithVersionedKeys.
+// Primary client is returned for NewRedisCacheWithReplica.
+func (r *RedisCache[T, V]) Client() redis.Cmdable {
+	return r.client
+}
+
+// readClient returns replica for reads of keys, unless some of them were written recently, see WithReadYourWrites.
+func (r *RedisCache[T, V]) readClient(keys ...string) redis.Cmdable {
+	if r.replica == nil || r.slidingTtl > 0 || r.recent.contains(keys...) {
+		return r.client
 	}
+
+	return r.replica
 }
 
+// Get reports entry which can not be decoded as missing and logs it at Warn, as MGet does,
+// so only redis failures are returned.
 func (r *RedisCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
-	cmd := r.client.Get(ctx, key.Key)
+	if !r.breaker.allow() {
+		return nil, nil
+	}
+
+	redisKey := r.versionedKey(key.Key, requiredModelVersion)
+
+	cmd := r.get(ctx, r.readClient(key.Key), redisKey)
 
 	if cmd.Err() != nil {
 		if errors.Is(cmd.Err(), redis.Nil) {
+			r.breaker.record(nil)
 			return nil, nil
 		}
+
+		r.breaker.record(cmd.Err())
 		return nil, errors.WithStack(cmd.Err())
 	}
 
+	r.breaker.record(nil)
+
 	bts, err := cmd.Bytes()
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	var item T
-	if err = msgpack.Unmarshal(bts, &item); err != nil {
-		return nil, errors.WithStack(err)
+	item, err := r.decode(bts, requiredModelVersion)
+	r.reportCorruption(key.Key, bts, err)
+
+	if r.shouldDrop(err) {
+		r.drop(ctx, []string{redisKey})
 	}
 
-	if item.GetCacheModelVersion() != requiredModelVersion {
+	switch {
+	case errors.Is(err, ErrTombstone):
+		return nil, err
+	case errors.Is(err, errStaleVersion):
+		return nil, nil
+	case err != nil: // corrupt entry is a miss as in MGet, only backend failures are returned
+		r.logger.Warn(err, "can not decode cached value", keyCount(1))
 		return nil, nil
 	}
 
-	return &item, nil
+	return item, nil
+}
+
+// Increment atomically adds delta to counter with INCRBY and returns new value, missing counter starts at zero.
+// Counter has no expiration and is not an encoded entity, see Incrementer.
+func (r *RedisCache[T, V]) Increment(ctx context.Context, key *Key[V], delta int64) (int64, error) {
+	v, err := r.client.IncrBy(ctx, r.redisKey(key.Key), delta).Result()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return v, nil
+}
+
+// redisWatcher is implemented by redis clients supporting transactions, e.g. *redis.Client and *redis.ClusterClient.
+type redisWatcher interface {
+	Watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error
+}
+
+// Update runs fn inside WATCH/MULTI/EXEC and retries it until key is not changed concurrently
+// or ctx is done, see Updater. Entries which can not be decoded are passed to fn as missing.
+func (r *RedisCache[T, V]) Update(
+	ctx context.Context,
+	key *Key[V],
+	modelVersion uint16,
+	ttl time.Duration,
+	fn func(current *T) (*T, error),
+) (*T, error) {
+	watcher, ok := r.client.(redisWatcher)
+	if !ok {
+		return nil, errors.Wrap(ErrUpdateNotSupported, "redis client does not support transactions")
+	}
+
+	if !r.breaker.allow() {
+		return nil, errors.WithStack(ErrCircuitOpen)
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	if err := r.registerVersions(ctx, r.client, modelVersion); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	r.recent.add(key.Key)
+	redisKey := r.versionedKey(key.Key, modelVersion)
+
+	for {
+		var next *T
+		var fnErr error
+
+		err := watcher.Watch(ctx, func(tx *redis.Tx) error {
+			bts, err := tx.Get(ctx, redisKey).Bytes()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+
+			var current *T
+			if err == nil {
+				current, _ = r.decode(bts, modelVersion)
+			}
+
+			if next, fnErr = fn(current); fnErr != nil {
+				return fnErr
+			}
+
+			var encoded []byte
+			if next != nil {
+				if encoded, err = r.encode(next); err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if next == nil {
+					pipe.Del(ctx, redisKey)
+				} else {
+					pipe.Set(ctx, redisKey, encoded, ttl)
+				}
+
+				return nil
+			})
+
+			return err
+		}, redisKey)
+
+		if fnErr != nil {
+			return nil, fnErr
+		}
+
+		if errors.Is(err, redis.TxFailedErr) && ctx.Err() == nil {
+			continue // key was changed after WATCH
+		}
+
+		r.breaker.record(err)
+
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return next, nil
+	}
+}
+
+// TTL returns remaining ttl of key with PTTL, see TTLReader.
+// With WithVersionedKeys the longest ttl across model versions written to redis is returned.
+func (r *RedisCache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	client := r.readClient(key.Key)
+
+	redisKeys, err := r.allVersionKeys(ctx, client, []*Key[V]{key})
+	if err != nil {
+		return TTLNotFound, errors.WithStack(err)
+	}
+
+	cmds := make([]*redis.DurationCmd, 0, len(redisKeys))
+
+	if _, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range redisKeys {
+			cmds = append(cmds, pipe.PTTL(ctx, k))
+		}
+
+		return nil
+	}); err != nil {
+		return TTLNotFound, errors.WithStack(err)
+	}
+
+	ttl := TTLNotFound
+	for _, cmd := range cmds {
+		ttl = longerTtl(ttl, cmd.Val())
+	}
+
+	return ttl, nil
+}
+
+// Ping checks redis connectivity with PING, see Pinger.
+func (r *RedisCache[T, V]) Ping(ctx context.Context) error {
+	return errors.WithStack(r.client.Ping(ctx).Err())
+}
+
+// Exists reports whether key is stored, with WithVersionedKeys in any model version written to redis.
+func (r *RedisCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if !r.breaker.allow() {
+		return false, nil
+	}
+
+	client := r.readClient(key.Key)
+
+	redisKeys, err := r.allVersionKeys(ctx, client, []*Key[V]{key})
+	if err != nil {
+		r.breaker.record(err)
+		return false, errors.WithStack(err)
+	}
+
+	if len(redisKeys) == 0 {
+		r.breaker.record(nil)
+		return false, nil
+	}
+
+	count, err := client.Exists(ctx, redisKeys...).Result()
+	r.breaker.record(err)
+
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return count > 0, nil
+}
+
+func (r *RedisCache[T, V]) encode(item *T) ([]byte, error) {
+	return encodeEntity(r.codec, r.compression, item)
+}
+
+func (r *RedisCache[T, V]) decode(bts []byte, requiredModelVersion uint16) (*T, error) {
+	return decodeEntity[T](r.codec, bts, requiredModelVersion)
+}
+
+// circuitOpen reports whether circuit breaker is open, see circuitOpen.
+func (r *RedisCache[T, V]) circuitOpen() bool {
+	return !r.breaker.allow()
+}
+
+// reportCorruption passes entry which can not be decoded to handler of WithCorruptionHandler.
+// Tombstones and stale model versions are not corruption.
+func (r *RedisCache[T, V]) reportCorruption(key string, raw []byte, decodeErr error) {
+	if r.onCorruption == nil || !isCorruption(decodeErr) {
+		return
+	}
+
+	r.onCorruption(key, raw, decodeErr)
 }
 
-func (r *RedisCache[T, V]) chunkBy(items []*Key[V], chunkSize int) (chunks [][]*Key[V]) {
+// isCorruption reports whether decode error means entry is corrupt rather than tombstone or stale version.
+func isCorruption(decodeErr error) bool {
+	return decodeErr != nil && !errors.Is(decodeErr, ErrTombstone) && !errors.Is(decodeErr, errStaleVersion)
+}
+
+// shouldDrop reports whether entry with given decode error should be deleted,
+// see WithSelfHealCorruptEntries and WithKeepStaleVersions.
+func (r *RedisCache[T, V]) shouldDrop(decodeErr error) bool {
+	switch {
+	case decodeErr == nil, errors.Is(decodeErr, ErrTombstone):
+		return false
+	case errors.Is(decodeErr, errStaleVersion):
+		return !r.keepStale
+	default:
+		return r.selfHeal
+	}
+}
+
+// drop is best-effort deletion of entries found invalid on read.
+func (r *RedisCache[T, V]) drop(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	if _, err := r.del(ctx, r.client, keys); err != nil {
+		r.logger.Error(err, "can not delete invalid values", keyCount(len(keys)))
+	}
+}
+
+func (r *RedisCache[T, V]) redisKey(key string) string {
+	return r.keyPrefix + key
+}
+
+// versionedKey is redisKey which includes model version when WithVersionedKeys is enabled.
+func (r *RedisCache[T, V]) versionedKey(key string, modelVersion uint16) string {
+	if !r.versionedKeys {
+		return r.redisKey(key)
+	}
+
+	return r.redisKey("v" + strconv.Itoa(int(modelVersion)) + ":" + key)
+}
+
+// prefixPattern is SCAN pattern of keys starting with prefix, with WithVersionedKeys in any model version.
+// Version glob may match beyond version itself, so matched keys are checked with logicalKey.
+func (r *RedisCache[T, V]) prefixPattern(prefix string) string {
+	pattern := escapeRedisPattern(r.keyPrefix)
+	if r.versionedKeys {
+		pattern += "v[0-9]*:"
+	}
+
+	return pattern + escapeRedisPattern(prefix) + "*"
+}
+
+// logicalKey strips key prefix and model version of WithVersionedKeys from redis key,
+// false is returned for keys which are not entries, e.g. set of model versions.
+func (r *RedisCache[T, V]) logicalKey(redisKey string) (string, bool) {
+	key := strings.TrimPrefix(redisKey, r.keyPrefix)
+	if !r.versionedKeys {
+		return key, true
+	}
+
+	version, rest, ok := strings.Cut(key, ":")
+	if !ok || !strings.HasPrefix(version, "v") {
+		return "", false
+	}
+
+	if _, err := strconv.ParseUint(version[1:], 10, 16); err != nil {
+		return "", false
+	}
+
+	return rest, true
+}
+
+// entityVersion returns model version of entity, Unversioned for nil entity.
+func entityVersion[T Entity](item *T) uint16 {
+	if item == nil {
+		return Unversioned
+	}
+
+	return (*item).GetCacheModelVersion()
+}
+
+// versionsKey is set of model versions written with WithVersionedKeys, so keys of every version
+// can be addressed by Delete and Exists of any provider instance, including freshly started ones.
+func (r *RedisCache[T, V]) versionsKey() string {
+	return r.redisKey(versionsKeyName)
+}
+
+// addVersions queues registration of written model versions into pipe, see versionsKey.
+func (r *RedisCache[T, V]) addVersions(ctx context.Context, pipe redis.Cmdable, versions ...uint16) {
+	if !r.versionedKeys || len(versions) == 0 {
+		return
+	}
+
+	members := make([]interface{}, 0, len(versions))
+	for _, v := range versions {
+		members = append(members, v)
+	}
+
+	pipe.SAdd(ctx, r.versionsKey(), members...)
+}
+
+// registerVersions is addVersions sent on its own.
+func (r *RedisCache[T, V]) registerVersions(ctx context.Context, client redis.Cmdable, versions ...uint16) error {
+	if !r.versionedKeys {
+		return nil
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.addVersions(ctx, pipe, versions...)
+
+		return nil
+	})
+
+	return err
+}
+
+// allVersionKeys returns redis keys of keys in every model version registered in versionsKey,
+// or plain redis keys when WithVersionedKeys is disabled.
+func (r *RedisCache[T, V]) allVersionKeys(ctx context.Context, client redis.Cmdable, keys []*Key[V]) ([]string, error) {
+	if !r.versionedKeys {
+		redisKeys := make([]string, 0, len(keys))
+		for _, k := range keys {
+			redisKeys = append(redisKeys, r.redisKey(k.Key))
+		}
+
+		return redisKeys, nil
+	}
+
+	versions, err := client.SMembers(ctx, r.versionsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	redisKeys := make([]string, 0, len(keys)*len(versions))
+
+	for _, k := range keys {
+		for _, v := range versions {
+			redisKeys = append(redisKeys, r.redisKey("v"+v+":"+k.Key))
+		}
+	}
+
+	return redisKeys, nil
+}
+
+// distinctVersions returns model versions of values, see versionsKey.
+func distinctVersions[T Entity](values map[string]*T) []uint16 {
+	seen := map[uint16]struct{}{}
+
+	var versions []uint16
+	for _, v := range values {
+		version := entityVersion(v)
+
+		if _, ok := seen[version]; !ok {
+			seen[version] = struct{}{}
+			versions = append(versions, version)
+		}
+	}
+
+	return versions
+}
+
+func chunkBy[K any](items []K, chunkSize int) (chunks [][]K) {
 	for chunkSize < len(items) {
 		items, chunks = items[chunkSize:], append(chunks, items[0:chunkSize:chunkSize])
 	}
 	return append(chunks, items)
 }
 
+func sortedKeys[T any](values map[string]*T) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
 type redisChunkResponse[T, V any] struct {
-	Error   error
-	Missing []*Key[V]
-	Results map[*Key[V]]*T
+	Error    error
+	KeyCount int
+	Missing  []*Key[V]
+	Results  map[*Key[V]]*T
 }
 
+// MGet reads chunks concurrently, failed chunks are logged and their keys reported as missing.
+// Once ctx is done MGet returns results of chunks received so far, keys of the rest are missing.
 func (r *RedisCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
-	chunks := r.chunkBy(keys, r.chunkSize)
+	if !r.breaker.allow() {
+		return map[*Key[V]]*T{}, keys, nil
+	}
+
+	chunks := chunkBy(keys, r.chunkSize)
 
 	var respChannels []chan redisChunkResponse[T, V]
 
+	sem := make(chan struct{}, r.maxConcurrency)
+
 	for _, chunk := range chunks {
 		chCopy := chunk
-		ch := make(chan redisChunkResponse[T, V])
+		// buffered, so chunk goroutine does not block once MGet returned on cancelled context
+		ch := make(chan redisChunkResponse[T, V], 1)
 		respChannels = append(respChannels, ch)
 
 		go func() {
@@ -81,111 +532,736 @@ func (r *RedisCache[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredMod
 				close(ch)
 			}()
 
-			strSlice := make([]string, 0, len(chCopy))
-
-			for _, v := range chCopy {
-				strSlice = append(strSlice, v.Key)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				ch <- redisChunkResponse[T, V]{Error: errors.WithStack(ctx.Err()), KeyCount: len(chCopy), Missing: chCopy}
+				return
 			}
 
-			cmd := r.client.MGet(ctx, strSlice...)
+			resp := r.getChunk(ctx, chCopy, requiredModelVersion)
+			<-sem
 
-			if cmd.Err() != nil {
-				ch <- redisChunkResponse[T, V]{
-					Error: errors.WithStack(cmd.Err()),
-				}
-				return
+			ch <- resp
+		}()
+	}
+
+	var missing []*Key[V]
+	var chunkErr error
+	results := make(map[*Key[V]]*T, len(keys))
+
+	collect := func(resp redisChunkResponse[T, V]) {
+		if resp.Error != nil && ctx.Err() == nil {
+			r.logger.Error(resp.Error, "can not get chunk from redis", keyCount(resp.KeyCount))
+			chunkErr = resp.Error
+		}
+
+		missing = append(missing, resp.Missing...)
+
+		for k, v := range resp.Results {
+			results[k] = v
+		}
+	}
+
+	for i, ch := range respChannels {
+		select {
+		case resp := <-ch:
+			collect(resp)
+			continue
+		case <-ctx.Done():
+		}
+
+		// partial results are returned right away, keys of chunks still in flight are missing
+		for j := i; j < len(respChannels); j++ {
+			select {
+			case resp := <-respChannels[j]:
+				collect(resp)
+			default:
+				missing = append(missing, chunks[j]...)
 			}
+		}
 
-			var missing []*Key[V]
-			results := map[*Key[V]]*T{}
+		return results, missing, nil
+	}
 
-			for i, v := range cmd.Val() {
-				if v == nil {
-					missing = append(missing, chCopy[i])
-					continue
-				}
+	if ctx.Err() == nil { // cancellation of caller says nothing about redis health
+		r.breaker.record(chunkErr)
+	}
 
-				var item T
+	return results, missing, nil
+}
 
-				var toUnpack []byte
+// getChunk reads single chunk of keys, see MGet.
+func (r *RedisCache[T, V]) getChunk(ctx context.Context, chunk []*Key[V], requiredModelVersion uint16) redisChunkResponse[T, V] {
+	strSlice := make([]string, 0, len(chunk))
+	cacheKeys := make([]string, 0, len(chunk))
 
-				switch val := v.(type) {
-				case []byte:
-					toUnpack = val
-				case string:
-					toUnpack = []byte(val)
-				}
+	for _, v := range chunk {
+		strSlice = append(strSlice, r.versionedKey(v.Key, requiredModelVersion))
+		cacheKeys = append(cacheKeys, v.Key)
+	}
 
-				if err := msgpack.Unmarshal(toUnpack, &item); err != nil {
-					zerolog.Ctx(ctx).Err(err).Send() // todo looks like cache is invalid
-					missing = append(missing, chCopy[i])
-					continue
-				}
+	client := r.readClient(cacheKeys...) // whole chunk is read from primary when any of its keys was written recently
 
-				if item.GetCacheModelVersion() != requiredModelVersion {
-					continue
-				}
+	var vals []interface{}
+	err := r.retry(ctx, func() error {
+		var err error
+		vals, err = r.mget(ctx, client, strSlice)
 
-				results[chCopy[i]] = &item
-			}
+		return err
+	})
 
-			ch <- redisChunkResponse[T, V]{
-				Missing: missing,
-				Results: results,
-			}
-		}()
+	if err != nil {
+		return redisChunkResponse[T, V]{
+			Error:    errors.WithStack(err),
+			KeyCount: len(chunk),
+			Missing:  chunk, // backfilled once redis recovers
+		}
 	}
 
+	return r.decodeChunk(ctx, chunk, strSlice, vals, requiredModelVersion)
+}
+
+// decodeChunk decodes values of MGET reply aligned with chunk, nil values are reported as missing.
+func (r *RedisCache[T, V]) decodeChunk(
+	ctx context.Context,
+	chunk []*Key[V],
+	strSlice []string,
+	vals []interface{},
+	requiredModelVersion uint16,
+) redisChunkResponse[T, V] {
 	var missing []*Key[V]
-	results := map[*Key[V]]*T{}
+	var toDrop []string
+	results := make(map[*Key[V]]*T, len(vals))
 
-	for _, ch := range respChannels {
-		resp := <-ch
+	// single allocation for whole chunk, returned values share it, so it is freed once all of them are unused
+	items := make([]T, len(vals))
 
-		if resp.Error != nil {
-			zerolog.Ctx(ctx).Err(resp.Error).Send()
+	for i, v := range vals {
+		if v == nil {
+			missing = append(missing, chunk[i])
 			continue
 		}
 
-		if len(resp.Missing) > 0 {
-			missing = append(missing, resp.Missing...)
+		var toUnpack []byte
+
+		switch val := v.(type) {
+		case []byte:
+			toUnpack = val
+		case string:
+			toUnpack = []byte(val)
 		}
 
-		for k, v := range resp.Results {
-			results[k] = v
+		item := &items[i]
+		err := decodeEntityInto(r.codec, toUnpack, requiredModelVersion, item)
+		r.reportCorruption(chunk[i].Key, toUnpack, err)
+
+		if r.shouldDrop(err) {
+			toDrop = append(toDrop, strSlice[i])
+		}
+
+		if errors.Is(err, ErrTombstone) {
+			results[chunk[i]] = nil
+			continue
+		}
+
+		if errors.Is(err, errStaleVersion) {
+			missing = append(missing, chunk[i])
+			continue
+		}
+
+		if err != nil {
+			r.logger.Warn(err, "can not decode cached value", keyCount(1))
+			missing = append(missing, chunk[i])
+			continue
 		}
+
+		results[chunk[i]] = item
 	}
 
-	return results, missing, nil
+	r.drop(ctx, toDrop)
+
+	return redisChunkResponse[T, V]{
+		Missing: missing,
+		Results: results,
+	}
 }
 
+// MSet returns *FailedKeysError when part of values can not be encoded or written.
+// Keys are written in sorted order, so chunking and reported failed keys are reproducible.
+// Zero ttl stores values without expiration.
 func (r *RedisCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if !r.breaker.allow() {
+		return &FailedKeysError{Keys: sortedKeys(values), Err: errors.WithStack(ErrCircuitOpen)}
+	}
+
+	r.recent.add(sortedKeys(values)...)
+
 	var multiErr error
+	var failed []string
+	keys := make([]string, 0, len(values))
 	finalArr := make([]interface{}, 0, len(values)*2)
 
-	for k, v := range values {
-		if b, err := msgpack.Marshal(v); err != nil {
-			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+	for _, k := range sortedKeys(values) { // deterministic chunks and failed keys
+		b, err := r.encode(values[k])
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			failed = append(failed, k)
 			continue
-		} else {
-			finalArr = append(finalArr, k, b)
 		}
+
+		keys = append(keys, k)
+		finalArr = append(finalArr, r.versionedKey(k, entityVersion(values[k])), b)
 	}
 
-	if len(finalArr) == 0 {
-		return errors.Wrap(multiErr, "no items to continue")
+	var unchanged []string
+	if r.skipUnchanged {
+		keys, finalArr, unchanged = r.withoutUnchanged(ctx, keys, finalArr)
 	}
 
-	if err := r.client.MSet(ctx, finalArr).Err(); err != nil {
-		log.Logger.Err(err).Send()
-		return errors.WithStack(err)
+	var versions []uint16
+	if r.versionedKeys {
+		versions = distinctVersions(values)
+	}
+
+	var writeErr error
+
+	for i, chunk := range chunkBy(finalArr, r.chunkSize*2) {
+		if writeErr = r.retry(ctx, func() error {
+			return r.mset(ctx, chunk, versions, ttl)
+		}); writeErr != nil {
+			r.logger.Error(writeErr, "can not set values to redis", keyCount(len(chunk)/2))
+			multiErr = multierror.Append(multiErr, errors.WithStack(writeErr))
+			failed = append(failed, keys[i*r.chunkSize:]...)
+			keys = keys[:i*r.chunkSize]
+			break
+		}
 	}
 
-	for key := range values {
-		if err := r.client.Expire(context.Background(), key, ttl).Err(); err != nil {
-			zerolog.Ctx(ctx).Err(err).Send()
+	if writeErr == nil && len(unchanged) > 0 {
+		if writeErr = r.expire(ctx, unchanged, values, ttl); writeErr != nil {
+			r.logger.Error(writeErr, "can not extend ttl of unchanged values", keyCount(len(unchanged)))
+			multiErr = multierror.Append(multiErr, errors.WithStack(writeErr))
+			failed = append(failed, unchanged...)
 		}
 	}
 
+	r.breaker.record(writeErr)
+
+	if len(failed) > 0 {
+		return &FailedKeysError{Keys: failed, Err: multiErr}
+	}
+
 	return nil
 }
+
+// withoutUnchanged drops key value pairs which are already stored with the same bytes, see WithSkipUnchangedWrites,
+// and returns keys of dropped pairs separately. Pairs of chunk which can not be read are kept, so they are written as usual.
+func (r *RedisCache[T, V]) withoutUnchanged(
+	ctx context.Context,
+	keys []string,
+	pairs []interface{},
+) ([]string, []interface{}, []string) {
+	changedKeys := make([]string, 0, len(keys))
+	changedPairs := make([]interface{}, 0, len(pairs))
+	var unchangedKeys []string
+
+	for i, chunk := range chunkBy(keys, r.chunkSize) {
+		chunkPairs := pairs[i*r.chunkSize*2 : (i*r.chunkSize+len(chunk))*2]
+		redisKeys := make([]string, 0, len(chunk))
+
+		for j := 0; j < len(chunkPairs); j += 2 {
+			redisKeys = append(redisKeys, chunkPairs[j].(string))
+		}
+
+		stored, err := r.readStored(ctx, redisKeys)
+		if err != nil {
+			r.logger.Warn(err, "can not compare values with stored ones", keyCount(len(chunk)))
+		}
+
+		for j, k := range chunk {
+			if err == nil && stored[j] != nil && stored[j] == string(chunkPairs[j*2+1].([]byte)) {
+				unchangedKeys = append(unchangedKeys, k)
+				continue
+			}
+
+			changedKeys = append(changedKeys, k)
+			changedPairs = append(changedPairs, chunkPairs[j*2], chunkPairs[j*2+1])
+		}
+	}
+
+	return changedKeys, changedPairs, unchangedKeys
+}
+
+// expire resets ttl of values which were not rewritten as unchanged, non positive ttl removes expiration like SET does.
+func (r *RedisCache[T, V]) expire(ctx context.Context, keys []string, values map[string]*T, ttl time.Duration) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			redisKey := r.versionedKey(k, entityVersion(values[k]))
+
+			if ttl > 0 {
+				pipe.PExpire(ctx, redisKey, ttl)
+			} else {
+				pipe.Persist(ctx, redisKey)
+			}
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// readStored reads keys without sliding expiration, so comparing values does not extend their ttl.
+func (r *RedisCache[T, V]) readStored(ctx context.Context, keys []string) ([]interface{}, error) {
+	if !r.clusterMode {
+		return r.client.MGet(ctx, keys...).Result()
+	}
+
+	cmds := make([]getCmd, 0, len(keys))
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			cmds = append(cmds, getCmd{key: k, get: pipe.Get(ctx, k)})
+		}
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	return cmdValues(cmds)
+}
+
+// Delete sends DEL per chunk in single pipeline, so any amount of keys costs one round trip.
+// With WithVersionedKeys keys of every model version written to redis are removed, which costs one more round trip.
+func (r *RedisCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if !r.breaker.allow() {
+		return errors.WithStack(ErrCircuitOpen)
+	}
+
+	for _, k := range keys {
+		r.recent.add(k.Key)
+	}
+
+	redisKeys, err := r.allVersionKeys(ctx, r.client, keys)
+	if err != nil || len(redisKeys) == 0 {
+		r.breaker.record(err)
+		return errors.WithStack(err)
+	}
+
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, chunk := range chunkBy(redisKeys, r.chunkSize) {
+			if !r.clusterMode {
+				pipe.Del(ctx, chunk...)
+				continue
+			}
+
+			for _, k := range chunk {
+				pipe.Del(ctx, k)
+			}
+		}
+
+		return nil
+	})
+	r.breaker.record(err)
+
+	return errors.WithStack(err)
+}
+
+func (r *RedisCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if !r.breaker.allow() {
+		return errors.WithStack(ErrCircuitOpen)
+	}
+
+	r.recent.add(keys...)
+	tombstone := encodeTombstone(modelVersion)
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			pipe.Set(ctx, r.versionedKey(k, modelVersion), tombstone, ttl)
+		}
+
+		r.addVersions(ctx, pipe, modelVersion)
+
+		return nil
+	})
+	r.breaker.record(err)
+
+	return errors.WithStack(err)
+}
+
+// Clear removes all keys inside configured key prefix using SCAN + DEL on every master node.
+// It is O(n) over the namespace and should be used sparingly, FLUSHDB is never issued as instance may be shared.
+// Without WithKeyPrefix keys of the cache can not be told apart, so ErrClearNotSupported is returned.
+func (r *RedisCache[T, V]) Clear(ctx context.Context) error {
+	if r.keyPrefix == "" {
+		return errors.Wrap(ErrClearNotSupported, "key prefix is required to clear redis cache")
+	}
+
+	_, err := r.deleteMatching(ctx, escapeRedisPattern(r.keyPrefix)+"*", nil)
+
+	return err
+}
+
+// DeleteByPrefix removes keys starting with prefix using SCAN + DEL and returns amount of removed keys,
+// see PrefixDeleter. It is best-effort and not atomic: keys written during scan may survive.
+// With WithVersionedKeys keys of every model version are removed.
+func (r *RedisCache[T, V]) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	return r.deleteMatching(ctx, r.prefixPattern(prefix), func(redisKey string) bool {
+		key, ok := r.logicalKey(redisKey)
+
+		return ok && strings.HasPrefix(key, prefix)
+	})
+}
+
+// deleteMatching removes keys matching pattern and accepted by match, when it is not nil,
+// from every master node and returns amount of removed keys.
+func (r *RedisCache[T, V]) deleteMatching(ctx context.Context, pattern string, match func(redisKey string) bool) (int, error) {
+	if !r.breaker.allow() {
+		return 0, errors.WithStack(ErrCircuitOpen)
+	}
+
+	var mut sync.Mutex
+	removed := 0
+
+	deleteNode := func(ctx context.Context, client redis.Cmdable) error {
+		return r.scanNode(ctx, client, pattern, func(keys []string) (bool, error) {
+			if match != nil {
+				matched := keys[:0]
+
+				for _, k := range keys {
+					if match(k) {
+						matched = append(matched, k)
+					}
+				}
+
+				if keys = matched; len(keys) == 0 {
+					return true, nil
+				}
+			}
+
+			n, err := r.del(ctx, client, keys)
+
+			mut.Lock()
+			removed += n
+			mut.Unlock()
+
+			return true, err
+		})
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return deleteNode(ctx, node)
+		})
+	} else {
+		err = deleteNode(ctx, r.client)
+	}
+
+	r.breaker.record(err)
+
+	return removed, err
+}
+
+// Scan calls fn for keys starting with prefix until fn returns false. Values are not read.
+// It is best-effort: SCAN gives no snapshot, so keys changed during scan may be missed or reported twice.
+// With WithVersionedKeys keys are reported without model version, once per model version stored.
+func (r *RedisCache[T, V]) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	var mut sync.Mutex
+	stopped := false
+
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		return r.scanNode(ctx, client, r.prefixPattern(prefix), func(keys []string) (bool, error) {
+			mut.Lock()
+			defer mut.Unlock()
+
+			for _, k := range keys {
+				if stopped {
+					break
+				}
+
+				key, ok := r.logicalKey(k)
+				if !ok || !strings.HasPrefix(key, prefix) {
+					continue
+				}
+
+				stopped = !fn(key)
+			}
+
+			return !stopped, nil
+		})
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	}
+
+	return scan(ctx, r.client)
+}
+
+// scanNode passes batches of keys matching pattern to fn until fn returns false or error.
+func (r *RedisCache[T, V]) scanNode(
+	ctx context.Context,
+	client redis.Cmdable,
+	pattern string,
+	fn func(keys []string) (bool, error),
+) error {
+	var cursor uint64
+
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, int64(r.chunkSize)).Result()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if len(keys) > 0 {
+			proceed, err := fn(keys)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if !proceed {
+				return nil
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+
+		cursor = next
+	}
+}
+
+// getCmd is read of single key queued by queueGet.
+type getCmd struct {
+	key string
+	ttl *redis.DurationCmd // expiry before GETEX, nil without WithSlidingExpiration
+	get *redis.StringCmd
+}
+
+// queueGet queues read of key, GETEX PX resetting expiry with WithSlidingExpiration. GETEX extends
+// tombstones as well, so it is preceded by PTTL for restoreTombstones to keep their expiry.
+func (r *RedisCache[T, V]) queueGet(ctx context.Context, pipe redis.Pipeliner, key string) getCmd {
+	if r.slidingTtl <= 0 {
+		return getCmd{key: key, get: pipe.Get(ctx, key)}
+	}
+
+	return getCmd{
+		key: key,
+		ttl: pipe.PTTL(ctx, key),
+		get: pipe.GetEx(ctx, key, r.slidingTtl),
+	}
+}
+
+// restoreTombstones resets expiry of tombstones read by GETEX to the one they had before, so negatively
+// cached keys still expire while they are read. Failure is only logged, as value is already read.
+func (r *RedisCache[T, V]) restoreTombstones(ctx context.Context, client redis.Cmdable, cmds []getCmd) {
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, cmd := range cmds {
+			if cmd.ttl == nil || cmd.ttl.Err() != nil {
+				continue
+			}
+
+			if bts, err := cmd.get.Bytes(); err != nil || !isTombstone(bts) {
+				continue
+			}
+
+			switch ttl := cmd.ttl.Val(); {
+			case ttl > 0:
+				pipe.PExpire(ctx, cmd.key, ttl)
+			case ttl == -1: // tombstone without expiry
+				pipe.Persist(ctx, cmd.key)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		r.logger.Warn(err, "can not restore expiry of tombstones", keyCount(len(cmds)))
+	}
+}
+
+// get reads key, resetting expiry of entity with WithSlidingExpiration.
+func (r *RedisCache[T, V]) get(ctx context.Context, client redis.Cmdable, key string) *redis.StringCmd {
+	if r.slidingTtl <= 0 {
+		return client.Get(ctx, key)
+	}
+
+	var cmd getCmd
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		cmd = r.queueGet(ctx, pipe, key)
+
+		return nil
+	})
+
+	if err == nil || errors.Is(err, redis.Nil) {
+		r.restoreTombstones(ctx, client, []getCmd{cmd})
+	}
+
+	return cmd.get // error of pipeline is kept in cmd
+}
+
+// mget falls back to pipelined GET in cluster mode, as MGET of keys from different slots fails with CROSSSLOT.
+// Pipeline is also used for sliding expiration, as there is no multi key GETEX.
+func (r *RedisCache[T, V]) mget(ctx context.Context, client redis.Cmdable, keys []string) ([]interface{}, error) {
+	if !r.clusterMode && r.slidingTtl <= 0 {
+		return client.MGet(ctx, keys...).Result()
+	}
+
+	cmds := make([]getCmd, 0, len(keys))
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			cmds = append(cmds, r.queueGet(ctx, pipe, k))
+		}
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	if r.slidingTtl > 0 {
+		r.restoreTombstones(ctx, client, cmds)
+	}
+
+	return cmdValues(cmds)
+}
+
+// cmdValues converts replies of pipelined GET into MGET reply, nil for missing keys.
+func cmdValues(cmds []getCmd) ([]interface{}, error) {
+	vals := make([]interface{}, len(cmds))
+
+	for i, cmd := range cmds {
+		if err := cmd.get.Err(); err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		vals[i] = cmd.get.Val()
+	}
+
+	return vals, nil
+}
+
+// retry calls fn until it succeeds, fails with not transient error or attempts configured by WithRetry run out.
+func (r *RedisCache[T, V]) retry(ctx context.Context, fn func() error) error {
+	backoff := r.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.retries || !isTransientRedisError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// mset pipelines SET with expiration per key, so key never exists without ttl,
+// unlike MSET followed by EXPIRE. Pipeline also avoids CROSSSLOT in cluster mode.
+func (r *RedisCache[T, V]) mset(ctx context.Context, pairs []interface{}, versions []uint16, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			pipe.Set(ctx, pairs[i].(string), pairs[i+1], ttl)
+		}
+
+		r.addVersions(ctx, pipe, versions...)
+
+		return nil
+	})
+
+	return err
+}
+
+// del removes keys and returns amount of keys which existed.
+func (r *RedisCache[T, V]) del(ctx context.Context, client redis.Cmdable, keys []string) (int, error) {
+	if !r.clusterMode {
+		removed, err := client.Del(ctx, keys...).Result()
+
+		return int(removed), err
+	}
+
+	cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			pipe.Del(ctx, k)
+		}
+
+		return nil
+	})
+
+	removed := 0
+	for _, cmd := range cmds {
+		if intCmd, ok := cmd.(*redis.IntCmd); ok {
+			removed += int(intCmd.Val())
+		}
+	}
+
+	return removed, err
+}
+
+func escapeRedisPattern(pattern string) string {
+	var sb strings.Builder
+
+	for _, r := range pattern {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// isTransientRedisError reports whether err may disappear on its own, e.g. during failover.
+func isTransientRedisError(err error) bool {
+	if errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return true // network errors
+	}
+
+	for _, prefix := range []string{"MOVED ", "ASK ", "LOADING ", "READONLY ", "TRYAGAIN ", "CLUSTERDOWN ", "MASTERDOWN "} {
+		if strings.HasPrefix(redisErr.Error(), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Reserved hash fields holding model version and negative caching flag of RedisHashCache entries.
+const (
+	hashVersionField   = "__v"
+	hashTombstoneField = "__tombstone"
+)
+
+// RedisHashCache stores entities as redis hashes, so other consumers can read individual fields with HGET.
+// Fields are mapped by `redis` struct tags as in go-redis HSET and Scan, model version is kept in reserved __v field.
+type RedisHashCache[T Entity, V any] struct {
+	redis *RedisCache[T, V]
+}
+
+// NewRedisHashCache creates provider storing entities as redis hashes, T must be struct with `redis` field tags.
+// WithCodec, WithCompression, WithSlidingExpiration, WithRetry, WithCircuitBreaker and WithVersionedKeys
+// are not applicable.
+func NewRedisHashCache[T Entity, V any](
+	client redis.Cmdable,
+	opts ...ProviderOption,
+) Provider[T, V] {
+	opts = append(opts[:len(opts):len(opts)], WithVersionedKeys(false), WithCircuitBreaker(0, 0))
+
+	return &RedisHashCache[T, V]{
+		redis: NewRedisCache[T, V](client, opts...).(*RedisCache[T, V]),
+	}
+}
+
+// Client returns underlying redis client, see RedisCache.Client.
+func (r *RedisHashCache[T, V]) Client() redis.Cmdable {
+	return r.redis.client
+}
+
+func (r *RedisHashCache[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	redisKey := r.redis.redisKey(key.Key)
+
+	cmd := r.redis.client.HGetAll(ctx, redisKey)
+	if err := cmd.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	item, err := r.decode(cmd, requiredModelVersion)
+	r.reportCorruption(key.Key, cmd, err)
+
+	if r.redis.shouldDrop(err) {
+		r.redis.drop(ctx, []string{redisKey})
+	}
+
+	if errors.Is(err, errStaleVersion) {
+		return nil, nil
+	}
+
+	return item, err
+}
+
+// MGet sends HGETALL per key in single pipeline per chunk.
+func (r *RedisHashCache[T, V]) MGet(
+	ctx context.Context,
+	keys []*Key[V],
+	requiredModelVersion uint16,
+) (map[*Key[V]]*T, []*Key[V], error) {
+	var missing []*Key[V]
+	var toDrop []string
+	results := make(map[*Key[V]]*T, len(keys))
+
+	for _, chunk := range chunkBy(keys, r.redis.chunkSize) {
+		cmds := make([]*redis.MapStringStringCmd, 0, len(chunk))
+
+		if _, err := r.redis.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, k := range chunk {
+				cmds = append(cmds, pipe.HGetAll(ctx, r.redis.redisKey(k.Key)))
+			}
+
+			return nil
+		}); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		for i, k := range chunk {
+			item, err := r.decode(cmds[i], requiredModelVersion)
+			r.reportCorruption(k.Key, cmds[i], err)
+
+			if r.redis.shouldDrop(err) {
+				toDrop = append(toDrop, r.redis.redisKey(k.Key))
+			}
+
+			switch {
+			case errors.Is(err, ErrTombstone):
+				results[k] = nil
+			case err != nil:
+				if !errors.Is(err, errStaleVersion) {
+					r.redis.logger.Warn(err, "can not decode cached hash", keyCount(1))
+				}
+
+				missing = append(missing, k)
+			case item == nil:
+				missing = append(missing, k)
+			default:
+				results[k] = item
+			}
+		}
+	}
+
+	r.redis.drop(ctx, toDrop)
+
+	return results, missing, nil
+}
+
+// reportCorruption passes fields of hash which can not be decoded to handler of WithCorruptionHandler as JSON,
+// fields are marshaled only for corrupt entry.
+func (r *RedisHashCache[T, V]) reportCorruption(key string, cmd *redis.MapStringStringCmd, decodeErr error) {
+	if r.redis.onCorruption == nil || !isCorruption(decodeErr) {
+		return
+	}
+
+	raw, _ := json.Marshal(cmd.Val()) // map of strings always marshals
+
+	r.redis.reportCorruption(key, raw, decodeErr)
+}
+
+// decode returns nil item for missing key, errStaleVersion for stale model version
+// and ErrTombstone for negatively cached key.
+func (r *RedisHashCache[T, V]) decode(cmd *redis.MapStringStringCmd, requiredModelVersion uint16) (*T, error) {
+	fields := cmd.Val()
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	version, err := strconv.ParseUint(fields[hashVersionField], 10, 16)
+	if err != nil {
+		return nil, errors.Wrap(err, "can not parse model version of hash")
+	}
+
+	if !versionMatches(uint16(version), requiredModelVersion) {
+		return nil, errStaleVersion
+	}
+
+	if fields[hashTombstoneField] != "" {
+		return nil, ErrTombstone
+	}
+
+	item := new(T)
+	if err = cmd.Scan(item); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return item, nil
+}
+
+// MSet replaces hashes in MULTI/EXEC per chunk, so hash never has fields of previous value or no ttl.
+// Nil values delete keys.
+func (r *RedisHashCache[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	var multiErr error
+	var failed []string
+
+	for _, chunk := range chunkBy(sortedKeys(values), r.redis.chunkSize) {
+		if _, err := r.redis.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, k := range chunk {
+				redisKey := r.redis.redisKey(k)
+				item := values[k]
+
+				pipe.Del(ctx, redisKey)
+
+				if item == nil {
+					continue
+				}
+
+				pipe.HSet(ctx, redisKey, item)
+				pipe.HSet(ctx, redisKey, hashVersionField, (*item).GetCacheModelVersion())
+
+				if ttl > 0 {
+					pipe.Expire(ctx, redisKey, ttl)
+				}
+			}
+
+			return nil
+		}); err != nil {
+			r.redis.logger.Error(err, "can not set hashes to redis", keyCount(len(chunk)))
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+			failed = append(failed, chunk...)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &FailedKeysError{Keys: failed, Err: multiErr}
+	}
+
+	return nil
+}
+
+// SetTombstones stores hashes holding only model version and tombstone flag.
+func (r *RedisHashCache[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := r.redis.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			redisKey := r.redis.redisKey(k)
+
+			pipe.Del(ctx, redisKey)
+			pipe.HSet(ctx, redisKey, hashVersionField, modelVersion, hashTombstoneField, 1)
+
+			if ttl > 0 {
+				pipe.Expire(ctx, redisKey, ttl)
+			}
+		}
+
+		return nil
+	})
+
+	return errors.WithStack(err)
+}
+
+func (r *RedisHashCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	return r.redis.Delete(ctx, keys...)
+}
+
+// Clear removes all keys inside configured key prefix, see RedisCache.Clear.
+func (r *RedisHashCache[T, V]) Clear(ctx context.Context) error {
+	return r.redis.Clear(ctx)
+}
+
+// DeleteByPrefix removes keys starting with prefix, see RedisCache.DeleteByPrefix.
+func (r *RedisHashCache[T, V]) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	return r.redis.DeleteByPrefix(ctx, prefix)
+}
+
+func (r *RedisHashCache[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	return r.redis.Exists(ctx, key)
+}
+
+// Scan calls fn for keys starting with prefix, see Scanner.
+func (r *RedisHashCache[T, V]) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	return r.redis.Scan(ctx, prefix, fn)
+}
+
+// TTL returns remaining ttl of key with PTTL, see TTLReader.
+func (r *RedisHashCache[T, V]) TTL(ctx context.Context, key *Key[V]) (time.Duration, error) {
+	return r.redis.TTL(ctx, key)
+}
+
+// Ping checks redis connectivity with PING, see Pinger.
+func (r *RedisHashCache[T, V]) Ping(ctx context.Context) error {
+	return r.redis.Ping(ctx)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hashEntity struct {
+	Id           int    `redis:"id"`
+	Value        string `redis:"value"`
+	ModelVersion uint16 `redis:"model_version"`
+}
+
+func (e hashEntity) GetCacheModelVersion() uint16 {
+	return e.ModelVersion
+}
+
+func TestRedisHashCache(t *testing.T) {
+	srv, client := newTestRedis(t)
+	provider := NewRedisHashCache[hashEntity, int](client, WithKeyPrefix("hash:"))
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*hashEntity{
+		key1.Key: {Id: 1, Value: "first", ModelVersion: 7},
+		key2.Key: {Id: 2, Value: "second", ModelVersion: 6},
+	}, time.Minute))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, 7, time.Minute))
+
+	assert.Equal(t, "first", srv.HGet("hash:key1", "value"))
+	assert.Equal(t, "7", srv.HGet("hash:key1", hashVersionField))
+	assert.Equal(t, time.Minute, srv.TTL("hash:key1"))
+
+	v, err := provider.Get(context.TODO(), key1, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, &hashEntity{Id: 1, Value: "first", ModelVersion: 7}, v)
+
+	_, err = provider.Get(context.TODO(), key3, 7)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(found))
+	assert.Equal(t, "first", found[key1].Value)
+	assert.Nil(t, found[key3])
+	assert.Equal(t, []*Key[int]{key2}, missing)
+	assert.False(t, srv.Exists("hash:key2")) // stale version is deleted
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*hashEntity{
+		key1.Key: {Id: 1, ModelVersion: 7},
+	}, 0))
+	assert.Equal(t, "", srv.HGet("hash:key1", "value"))
+	assert.Equal(t, time.Duration(0), srv.TTL("hash:key1"))
+
+	assert.Nil(t, provider.Clear(context.TODO()))
+	assert.Empty(t, srv.Keys())
+}
+
+func TestRedisHashCacheCorruptionHandler(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	var corrupted []string
+	provider := NewRedisHashCache[hashEntity, int](client, WithCorruptionHandler(func(key string, raw []byte, err error) {
+		assert.NotNil(t, err)
+		corrupted = append(corrupted, key+" "+string(raw))
+	}))
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	srv.HSet(key1.Key, hashVersionField, "x")
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1}, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key1}, missing)
+	assert.Equal(t, []string{`key1 {"` + hashVersionField + `":"x"}`}, corrupted)
+	assert.False(t, srv.Exists(key1.Key))
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPipeline sends MGet requests of RedisCache providers with different entity types in single round trip.
+// Providers must store keys in redis client of the pipeline, their own clients are not used.
+type RedisPipeline struct {
+	client   redis.Cmdable
+	requests []pipelineRequest
+}
+
+type pipelineRequest interface {
+	enqueue(ctx context.Context, pipe redis.Pipeliner)
+	decode(ctx context.Context, client redis.Cmdable, err error)
+}
+
+// PipelineResult holds result of request enqueued by PipelineMGet, it is filled by RedisPipeline.Exec.
+type PipelineResult[T Entity, V any] struct {
+	Found   map[*Key[V]]*T // nil value for negatively cached key, as in Provider.MGet
+	Missing []*Key[V]
+	Err     error
+
+	provider     *RedisCache[T, V]
+	keys         []*Key[V]
+	redisKeys    []string
+	modelVersion uint16
+	cmds         []getCmd
+	skipped      bool // circuit breaker of provider is open
+}
+
+func NewRedisPipeline(client redis.Cmdable) *RedisPipeline {
+	return &RedisPipeline{client: client}
+}
+
+// PipelineMGet enqueues MGet of keys from provider created by NewRedisCache, possibly wrapped by WithProviderTtl
+// or WithBackfillOnly, result is available after Exec. Missing keys are not loaded from source, pass them to Cache.MGet.
+// Circuit breaker of provider is honored, keys of open one are missing without being sent. Retries and replica
+// of provider are not used, as keys are read by client of the pipeline in single round trip.
+func PipelineMGet[T Entity, V any](
+	p *RedisPipeline,
+	provider Provider[T, V],
+	keys []*Key[V],
+	modelVersion uint16,
+) (*PipelineResult[T, V], error) {
+	r, ok := UnwrapProvider(provider).(*RedisCache[T, V])
+	if !ok {
+		return nil, errors.Errorf("provider %v does not support pipeline", providerName(provider))
+	}
+
+	res := &PipelineResult[T, V]{
+		provider:     r,
+		keys:         keys,
+		modelVersion: modelVersion,
+	}
+	p.requests = append(p.requests, res)
+
+	return res, nil
+}
+
+// Exec sends all enqueued requests in single pipeline and resets the pipeline. Error of whole pipeline
+// is also set to every result, errors of individual requests are reported only in their results.
+func (p *RedisPipeline) Exec(ctx context.Context) error {
+	requests := p.requests
+	p.requests = nil
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	_, err := p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, req := range requests {
+			req.enqueue(ctx, pipe)
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, redis.Nil) { // reply of the first missing key
+		err = nil
+	}
+
+	err = errors.WithStack(err)
+
+	for _, req := range requests {
+		req.decode(ctx, p.client, err)
+	}
+
+	return err
+}
+
+func (res *PipelineResult[T, V]) enqueue(ctx context.Context, pipe redis.Pipeliner) {
+	r := res.provider
+
+	if res.skipped = !r.breaker.allow(); res.skipped {
+		return
+	}
+
+	res.redisKeys = make([]string, 0, len(res.keys))
+	res.cmds = make([]getCmd, 0, len(res.keys))
+
+	for _, k := range res.keys {
+		redisKey := r.versionedKey(k.Key, res.modelVersion)
+
+		res.redisKeys = append(res.redisKeys, redisKey)
+		res.cmds = append(res.cmds, r.queueGet(ctx, pipe, redisKey))
+	}
+}
+
+func (res *PipelineResult[T, V]) decode(ctx context.Context, client redis.Cmdable, err error) {
+	if res.skipped {
+		res.Found, res.Missing = map[*Key[V]]*T{}, res.keys
+		return
+	}
+
+	if ctx.Err() == nil { // cancellation of caller says nothing about redis health
+		res.provider.breaker.record(err)
+	}
+
+	if err != nil {
+		res.Err = err
+		return
+	}
+
+	if res.provider.slidingTtl > 0 {
+		res.provider.restoreTombstones(ctx, client, res.cmds)
+	}
+
+	vals, err := cmdValues(res.cmds)
+	if err != nil {
+		res.Err = errors.WithStack(err)
+		return
+	}
+
+	resp := res.provider.decodeChunk(ctx, res.keys, res.redisKeys, vals, res.modelVersion)
+	res.Found, res.Missing = resp.Results, resp.Missing
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripsHook struct {
+	count atomic.Int32
+}
+
+func (h *roundTripsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *roundTripsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.count.Add(1)
+
+		return next(ctx, cmd)
+	}
+}
+
+func (h *roundTripsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.count.Add(1)
+
+		return next(ctx, cmds)
+	}
+}
+
+func TestRedisPipelineMGet(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	entities := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("entity:"))
+	others := NewRedisCache[hashEntity, string](client, WithKeyPrefix("other:"))
+
+	key1 := &Key[int]{Key: "1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "3", OriginalValue: 3}
+	keyA := &Key[string]{Key: "a", OriginalValue: "a"}
+	keyB := &Key[string]{Key: "b", OriginalValue: "b"}
+
+	assert.Nil(t, entities.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+	assert.Nil(t, entities.SetTombstones(context.TODO(), []string{key3.Key}, currentModelVersion, time.Hour))
+	assert.Nil(t, others.MSet(context.TODO(), map[string]*hashEntity{
+		keyA.Key: {Value: "a", ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	hook := &roundTripsHook{}
+	client.AddHook(hook)
+
+	p := NewRedisPipeline(client)
+
+	entityRes, err := PipelineMGet(p, entities, []*Key[int]{key1, key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+
+	otherRes, err := PipelineMGet(p, others, []*Key[string]{keyA, keyB}, currentModelVersion)
+	assert.Nil(t, err)
+
+	assert.Nil(t, p.Exec(context.TODO()))
+	assert.Equal(t, int32(1), hook.count.Load())
+
+	assert.Nil(t, entityRes.Err)
+	assert.Equal(t, map[*Key[int]]*EntityToCache{
+		key1: {Id: 1, ModelVersion: currentModelVersion},
+		key3: nil,
+	}, entityRes.Found)
+	assert.Equal(t, []*Key[int]{key2}, entityRes.Missing)
+
+	assert.Nil(t, otherRes.Err)
+	assert.Equal(t, "a", otherRes.Found[keyA].Value)
+	assert.Equal(t, []*Key[string]{keyB}, otherRes.Missing)
+
+	assert.Nil(t, p.Exec(context.TODO())) // requests are reset
+	assert.Equal(t, int32(1), hook.count.Load())
+
+	_, err = PipelineMGet(p, NewMapCache[EntityToCache, int](), []*Key[int]{key1}, currentModelVersion)
+	assert.Error(t, err)
+}
+
+func TestRedisPipelineMGetError(t *testing.T) {
+	_, client := newTestRedis(t)
+	client.AddHook(&failingHook{failures: 1})
+
+	p := NewRedisPipeline(client)
+
+	res, err := PipelineMGet(p, NewRedisCache[EntityToCache, int](client), []*Key[int]{{Key: "1"}}, 1)
+	assert.Nil(t, err)
+
+	assert.Error(t, p.Exec(context.TODO()))
+	assert.Error(t, res.Err)
+	assert.Nil(t, res.Found)
+}
+
+func TestRedisPipelineMGetWrappedProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	key := &Key[int]{Key: "1", OriginalValue: 1}
+	provider := NewRedisCache[EntityToCache, int](client)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	p := NewRedisPipeline(client)
+
+	res, err := PipelineMGet(p, WithBackfillOnly(WithProviderTtl(provider, time.Minute)), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+
+	assert.Nil(t, p.Exec(context.TODO()))
+	assert.Nil(t, res.Err)
+	assert.Equal(t, 1, res.Found[key].Id)
+}
+
+func TestRedisPipelineMGetCircuitBreaker(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	failing := &failingHook{failures: 1}
+	client.AddHook(failing)
+
+	key := &Key[int]{Key: "1", OriginalValue: 1}
+	provider := NewRedisCache[EntityToCache, int](client, WithCircuitBreaker(1, time.Minute))
+
+	p := NewRedisPipeline(client)
+
+	res, err := PipelineMGet(p, provider, []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Error(t, p.Exec(context.TODO()))
+	assert.Error(t, res.Err)
+
+	hook := &roundTripsHook{}
+	client.AddHook(hook)
+
+	res, err = PipelineMGet(p, provider, []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, p.Exec(context.TODO()))
+	assert.Nil(t, res.Err)
+	assert.Empty(t, res.Found)
+	assert.Equal(t, []*Key[int]{key}, res.Missing)
+	assert.Equal(t, int32(0), hook.count.Load()) // keys of open breaker are not sent
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisCacheWithReplica creates redis provider which reads Get, MGet and Exists from replica
+// and sends writes to primary. Replication is asynchronous, so value written through the provider
+// may be missing or stale on replica for a moment, use WithReadYourWrites to read such keys from primary.
+// Reads are sent to primary with WithSlidingExpiration, as GETEX and PEXPIRE
+// restoring expiry of tombstones are rejected by read-only replica.
+func NewRedisCacheWithReplica[T Entity, V any](
+	primary redis.Cmdable,
+	replica redis.Cmdable,
+	opts ...ProviderOption,
+) Provider[T, V] {
+	r := NewRedisCache[T, V](primary, opts...).(*RedisCache[T, V])
+	r.replica = replica
+
+	return r
+}
+
+// recentKeys remembers keys written within window. Keys are kept in two generations rotated every window,
+// so memory is bounded by keys written within last two windows without scanning on every write.
+type recentKeys struct {
+	mut       sync.Mutex
+	window    time.Duration
+	current   map[string]time.Time
+	previous  map[string]time.Time
+	rotatedAt time.Time
+	now       func() time.Time
+}
+
+func newRecentKeys(window time.Duration) *recentKeys {
+	if window <= 0 {
+		return nil
+	}
+
+	return &recentKeys{
+		window:  window,
+		current: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+func (k *recentKeys) add(keys ...string) {
+	if k == nil {
+		return
+	}
+
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	now := k.now()
+
+	if now.Sub(k.rotatedAt) >= k.window {
+		k.previous, k.current = k.current, make(map[string]time.Time, len(k.current))
+		k.rotatedAt = now
+	}
+
+	for _, key := range keys {
+		k.current[key] = now
+	}
+}
+
+// contains reports whether any of keys was written within window, nil recentKeys contains nothing.
+func (k *recentKeys) contains(keys ...string) bool {
+	if k == nil {
+		return false
+	}
+
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	now := k.now()
+
+	for _, key := range keys {
+		writtenAt, ok := k.current[key]
+		if !ok {
+			writtenAt, ok = k.previous[key]
+		}
+
+		if ok && now.Sub(writtenAt) < k.window {
+			return true
+		}
+	}
+
+	return false
+}
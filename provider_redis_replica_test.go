@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCacheWithReplica(t *testing.T) {
+	currentModelVersion := uint16(7)
+	primarySrv, primary := newTestRedis(t)
+	_, replica := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	provider := NewRedisCacheWithReplica[EntityToCache, int](primary, replica)
+	replicated := NewRedisCache[EntityToCache, int](replica)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+	assert.True(t, primarySrv.Exists(key1.Key))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v) // not replicated yet
+
+	assert.Nil(t, replicated.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, Value: "replica", ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, Value: "replica", ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	v, err = provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "replica", v.Value)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, "replica", found[key1].Value)
+	assert.Equal(t, "replica", found[key2].Value)
+
+	exists, err := provider.Exists(context.TODO(), key2)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	assert.Nil(t, provider.Delete(context.TODO(), key2))
+	assert.Equal(t, int64(1), replica.Exists(context.TODO(), key2.Key).Val()) // delete is not sent to replica
+}
+
+func TestRedisCacheWithReplicaReadYourWrites(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, primary := newTestRedis(t)
+	_, replica := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	provider := NewRedisCacheWithReplica[EntityToCache, int](primary, replica, WithReadYourWrites(time.Minute))
+	now := time.Now()
+	provider.(*RedisCache[EntityToCache, int]).recent.now = func() time.Time {
+		return now
+	}
+
+	assert.Nil(t, NewRedisCache[EntityToCache, int](replica).MSet(context.TODO(), map[string]*EntityToCache{
+		key2.Key: {Id: 2, Value: "replica", ModelVersion: currentModelVersion},
+	}, time.Hour))
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, Value: "primary", ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "primary", v.Value)
+
+	v, err = provider.Get(context.TODO(), key2, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "replica", v.Value)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing) // chunk with recently written key is read from primary
+	assert.Equal(t, "primary", found[key1].Value)
+
+	now = now.Add(time.Minute)
+
+	v, err = provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestRecentKeys(t *testing.T) {
+	now := time.Now()
+	k := newRecentKeys(time.Minute)
+	k.now = func() time.Time {
+		return now
+	}
+
+	k.add("key1")
+	now = now.Add(30 * time.Second)
+	k.add("key2")
+
+	assert.True(t, k.contains("key1"))
+	assert.True(t, k.contains("key3", "key2"))
+	assert.False(t, k.contains("key3"))
+
+	now = now.Add(40 * time.Second)
+	k.add("key3") // rotates generations
+
+	assert.False(t, k.contains("key1"))
+	assert.True(t, k.contains("key2"))
+	assert.Len(t, k.previous, 2)
+	assert.Len(t, k.current, 1)
+
+	assert.False(t, (*recentKeys)(nil).contains("key1"))
+	assert.Nil(t, newRecentKeys(0))
+}
@@ -0,0 +1,1087 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	srv := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: srv.Addr(),
+	})
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return srv, client
+}
+
+func TestRedisCacheKeyPrefixIsolation(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	first := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("first:"))
+	second := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("second:"))
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	assert.Nil(t, first.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "first", ModelVersion: currentModelVersion},
+	}, time.Minute))
+	assert.Nil(t, second.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "second", ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	assert.ElementsMatch(t, []string{"first:entity:1", "second:entity:1"}, srv.Keys())
+
+	v, err := first.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", v.Value)
+
+	found, missing, err := second.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, "second", found[key].Value)
+	assert.Equal(t, "entity:1", key.Key)
+
+	assert.Nil(t, first.Delete(context.TODO(), key))
+	assert.Equal(t, []string{"second:entity:1"}, srv.Keys())
+
+	assert.Nil(t, first.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "first", ModelVersion: currentModelVersion},
+	}, time.Minute))
+	assert.Nil(t, second.Clear(context.TODO()))
+	assert.Equal(t, []string{"first:entity:1"}, srv.Keys())
+}
+
+func TestRedisCacheClearRequiresPrefix(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	assert.ErrorIs(t, NewRedisCache[EntityToCache, int](client).Clear(context.TODO()), ErrClearNotSupported)
+}
+
+func TestRedisCacheChunkSize(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(2))
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 5; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+	}
+
+	assert.Nil(t, provider.MSet(context.TODO(), values, time.Minute))
+
+	found, missing, err := provider.MGet(context.TODO(), keys, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, 5, len(found))
+
+	for _, key := range keys {
+		assert.Equal(t, key.OriginalValue, found[key].Id)
+	}
+}
+
+func TestRedisCacheClusterMode(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithClusterMode(true), WithKeyPrefix("app:"))
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "entity:3", OriginalValue: 3}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key3}, missing)
+	assert.Equal(t, 1, found[key1].Id)
+	assert.Equal(t, 2, found[key2].Id)
+
+	assert.Nil(t, provider.Delete(context.TODO(), key1))
+	assert.Equal(t, []string{"app:entity:2"}, srv.Keys())
+
+	assert.Nil(t, provider.Clear(context.TODO()))
+	assert.Empty(t, srv.Keys())
+}
+
+func TestRedisCacheClusterClient(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv := miniredis.RunT(t) // miniredis answers CLUSTER SLOTS as single node owning every slot
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{srv.Addr()},
+	})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:")).(*RedisCache[EntityToCache, int])
+	assert.True(t, provider.clusterMode) // enabled for *redis.ClusterClient without option
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 10; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+	}
+
+	assert.Nil(t, provider.MSet(context.TODO(), values, time.Minute))
+
+	found, missing, err := provider.MGet(context.TODO(), keys, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Len(t, found, 10)
+
+	removed, err := provider.DeleteByPrefix(context.TODO(), "entity:")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, removed)
+
+	assert.Nil(t, provider.Clear(context.TODO()))
+}
+
+func TestRedisCacheSlidingExpiration(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithSlidingExpiration(time.Hour))
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		key2.Key: {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+	assert.Equal(t, time.Hour, srv.TTL(key1.Key))
+	assert.Equal(t, time.Minute, srv.TTL(key2.Key))
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, 2, found[key2].Id)
+	assert.Equal(t, time.Hour, srv.TTL(key2.Key))
+
+	key3 := &Key[int]{Key: "entity:3", OriginalValue: 3}
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, currentModelVersion, time.Minute))
+
+	srv.FastForward(time.Second)
+
+	_, err = provider.Get(context.TODO(), key3, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+	assert.Equal(t, time.Minute-time.Second, srv.TTL(key3.Key))
+
+	found, _, err = provider.MGet(context.TODO(), []*Key[int]{key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Contains(t, found, key3)
+	assert.Equal(t, time.Minute-time.Second, srv.TTL(key3.Key)) // tombstone expiry is not extended
+}
+
+func TestRedisCacheDeleteChunks(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(2))
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 5; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+	}
+
+	assert.Nil(t, provider.MSet(context.TODO(), values, time.Minute))
+	assert.Nil(t, provider.Delete(context.TODO(), keys[:4]...))
+	assert.Equal(t, []string{"entity:4"}, srv.Keys())
+}
+
+func TestRedisCacheExists(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"))
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	ok, err := provider.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key.Key}, 7, time.Minute))
+
+	ok, err = provider.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestRedisCacheLogsCorruptedEntryAsWarn(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	var buf bytes.Buffer
+	provider := NewRedisCache[EntityToCache, int](client, WithSlog(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	assert.Nil(t, srv.Set(key.Key, "not msgpack"))
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, []*Key[int]{key}, missing)
+
+	assert.Contains(t, buf.String(), `"level":"WARN"`)
+	assert.Contains(t, buf.String(), `"key_count":1`)
+}
+
+// TestRedisCacheMGetMapsChunksToOwnKeys guards against mapping results of later chunks to keys of the first one.
+func TestRedisCacheMGetMapsChunksToOwnKeys(t *testing.T) {
+	currentModelVersion := uint16(7)
+
+	for name, opts := range map[string][]ProviderOption{
+		"mget":    nil,
+		"cluster": {WithClusterMode(true)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+
+			provider := NewRedisCache[EntityToCache, int](client, opts...)
+
+			var keys []*Key[int]
+			values := map[string]*EntityToCache{}
+
+			for i := 0; i < DefaultChunkSize*2+7; i++ {
+				key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+				keys = append(keys, key)
+				values[key.Key] = &EntityToCache{Id: i, Value: key.Key, ModelVersion: currentModelVersion}
+			}
+
+			assert.Nil(t, provider.MSet(context.TODO(), values, time.Minute))
+
+			found, missing, err := provider.MGet(context.TODO(), keys, currentModelVersion)
+			assert.Nil(t, err)
+			assert.Empty(t, missing)
+			assert.Equal(t, len(keys), len(found))
+
+			for _, key := range keys {
+				assert.Equal(t, key.OriginalValue, found[key].Id)
+				assert.Equal(t, key.Key, found[key].Value)
+			}
+		})
+	}
+}
+
+func TestRedisCacheSelfHealCorruptEntries(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+
+	assert.Nil(t, srv.Set(key1.Key, "not msgpack"))
+	assert.Nil(t, srv.Set(key2.Key, "not msgpack"))
+
+	kept := NewRedisCache[EntityToCache, int](client, WithSelfHealCorruptEntries(false))
+
+	v, err := kept.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err) // corrupt entry is a miss
+	assert.Nil(t, v)
+	assert.True(t, srv.Exists(key1.Key))
+
+	provider := NewRedisCache[EntityToCache, int](client)
+
+	v, err = provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+	assert.False(t, srv.Exists(key1.Key))
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key2}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing)
+	assert.False(t, srv.Exists(key2.Key))
+}
+
+func TestRedisCacheCorruptionHandler(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "entity:3", OriginalValue: 3}
+
+	corrupted := map[string][]byte{}
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("p:"),
+		WithCorruptionHandler(func(key string, raw []byte, err error) {
+			assert.NotNil(t, err)
+			corrupted[key] = raw
+		}))
+
+	assert.Nil(t, srv.Set("p:"+key1.Key, "not msgpack"))
+	assert.Nil(t, srv.Set("p:"+key2.Key, "broken"))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key3.Key}, currentModelVersion, time.Minute))
+
+	v, err := provider.Get(context.TODO(), key1, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key2, key3}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing)
+
+	assert.Equal(t, map[string][]byte{
+		key1.Key: []byte("not msgpack"),
+		key2.Key: []byte("broken"),
+	}, corrupted) // tombstone is not reported
+	assert.False(t, srv.Exists("p:"+key1.Key)) // self-heal still deletes entries
+	assert.False(t, srv.Exists("p:"+key2.Key))
+}
+
+func TestRedisCacheEvictsStaleVersions(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	key1 := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "entity:2", OriginalValue: 2}
+
+	kept := NewRedisCache[EntityToCache, int](client, WithKeepStaleVersions(true))
+	provider := NewRedisCache[EntityToCache, int](client)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, ModelVersion: 1},
+		key2.Key: {Id: 2, ModelVersion: 1},
+	}, time.Minute))
+
+	v, err := kept.Get(context.TODO(), key1, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+	assert.True(t, srv.Exists(key1.Key))
+
+	v, err = provider.Get(context.TODO(), key1, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+	assert.False(t, srv.Exists(key1.Key))
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key2}, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key2}, missing)
+	assert.False(t, srv.Exists(key2.Key))
+}
+
+func TestRedisCacheIncrement(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"))
+	key := &Key[int]{Key: "counter", OriginalValue: 1}
+
+	incrementer, ok := provider.(Incrementer[int])
+	assert.True(t, ok)
+
+	v, err := incrementer.Increment(context.TODO(), key, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), v)
+
+	v, err = incrementer.Increment(context.TODO(), key, -2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), v)
+
+	stored, err := srv.Get("app:counter")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", stored)
+}
+
+func TestRedisCacheCircuitBreaker(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	failing := &failingHook{}
+	commands := &commandsHook{}
+	client.AddHook(commands)
+	client.AddHook(failing)
+
+	now := time.Unix(1700000000, 0)
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithCircuitBreaker(2, time.Minute)).(*RedisCache[EntityToCache, int])
+	provider.breaker.now = func() time.Time {
+		return now
+	}
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	failing.failures = 2
+	for i := 0; i < 2; i++ {
+		_, err := provider.Get(context.TODO(), key, currentModelVersion)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+
+	sent := len(commands.args)
+
+	v, err := provider.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	_, missing, err := provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Key[int]{key}, missing)
+
+	err = provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, provider.Delete(context.TODO(), key), ErrCircuitOpen)
+	assert.ErrorIs(t, provider.Clear(context.TODO()), ErrCircuitOpen)
+
+	_, err = provider.DeleteByPrefix(context.TODO(), "entity:")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, sent, len(commands.args))
+
+	now = now.Add(time.Minute)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	v, err = provider.Get(context.TODO(), key, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Id)
+}
+
+func TestRedisCacheScan(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	assert.Nil(t, srv.Set("other:user:3", "foreign"))
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithChunkSize(1))
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"user:1":  {Id: 1, ModelVersion: currentModelVersion},
+		"user:2":  {Id: 2, ModelVersion: currentModelVersion},
+		"order:1": {Id: 3, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	scanner, ok := provider.(Scanner)
+	assert.True(t, ok)
+
+	var keys []string
+	assert.Nil(t, scanner.Scan(context.TODO(), "user:", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, keys)
+
+	keys = nil
+	assert.Nil(t, scanner.Scan(context.TODO(), "", func(key string) bool {
+		keys = append(keys, key)
+		return false
+	}))
+	assert.Equal(t, 1, len(keys))
+}
+
+func TestRedisCacheScanVersionedKeys(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithVersionedKeys(true))
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"user:1":        {Id: 1, ModelVersion: 7},
+		"tenant:user:2": {Id: 2, ModelVersion: 7},
+	}, time.Hour))
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"user:3": {Id: 3, ModelVersion: 8},
+	}, time.Hour))
+
+	scanner := provider.(Scanner)
+
+	var keys []string
+	assert.Nil(t, scanner.Scan(context.TODO(), "user:", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.ElementsMatch(t, []string{"user:1", "user:3"}, keys)
+
+	keys = nil
+	assert.Nil(t, scanner.Scan(context.TODO(), "", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.ElementsMatch(t, []string{"user:1", "tenant:user:2", "user:3"}, keys) // no set of model versions
+}
+
+func TestRedisCacheVersionedKeys(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithVersionedKeys(true))
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "old", ModelVersion: 7},
+	}, time.Hour))
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "new", ModelVersion: 8},
+	}, time.Hour))
+
+	assert.True(t, srv.Exists("app:v7:entity:1"))
+	assert.True(t, srv.Exists("app:v8:entity:1"))
+
+	v, err := provider.Get(context.TODO(), key, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, "old", v.Value)
+
+	found, _, err := provider.MGet(context.TODO(), []*Key[int]{key}, 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", found[key].Value)
+
+	assert.Nil(t, provider.Delete(context.TODO(), key))
+	assert.False(t, srv.Exists("app:v7:entity:1")) // every model version is removed
+	assert.False(t, srv.Exists("app:v8:entity:1"))
+}
+
+func TestRedisCacheVersionedKeysFreshInstance(t *testing.T) {
+	srv, client := newTestRedis(t)
+
+	writer := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithVersionedKeys(true))
+	key := &Key[int]{Key: "a", OriginalValue: 1}
+
+	assert.Nil(t, writer.MSet(context.TODO(), map[string]*EntityToCache{key.Key: {Id: 1, ModelVersion: 7}}, time.Hour))
+	assert.Nil(t, writer.SetTombstones(context.TODO(), []string{"b"}, 6, time.Hour))
+
+	// e.g. another process or the same one after restart, it has not read or written any version yet
+	fresh := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:"), WithVersionedKeys(true))
+
+	exists, err := fresh.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	assert.Nil(t, fresh.Delete(context.TODO(), key, &Key[int]{Key: "b"}))
+	assert.False(t, srv.Exists("app:v7:a"))
+	assert.False(t, srv.Exists("app:v6:b"))
+
+	exists, err = fresh.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+// failingHook fails first failures commands or pipelines with connection error.
+type failingHook struct {
+	failures int
+}
+
+func (h *failingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *failingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if h.failures > 0 {
+			h.failures--
+			cmd.SetErr(io.ErrUnexpectedEOF)
+
+			return io.ErrUnexpectedEOF
+		}
+
+		return next(ctx, cmd)
+	}
+}
+
+func (h *failingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if h.failures > 0 {
+			h.failures--
+			for _, cmd := range cmds {
+				cmd.SetErr(io.ErrUnexpectedEOF)
+			}
+
+			return io.ErrUnexpectedEOF
+		}
+
+		return next(ctx, cmds)
+	}
+}
+
+// slowHook delays commands having slowArg among arguments.
+type slowHook struct {
+	slowArg string
+	delay   time.Duration
+}
+
+func (h *slowHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *slowHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		for _, arg := range cmd.Args() {
+			if arg == h.slowArg {
+				time.Sleep(h.delay)
+			}
+		}
+
+		return next(ctx, cmd)
+	}
+}
+
+func (h *slowHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisCacheMGetCancelled(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+	client.AddHook(&slowHook{slowArg: "slow", delay: 2 * time.Second})
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(1), WithCircuitBreaker(1, time.Minute))
+
+	fast1 := &Key[int]{Key: "fast1", OriginalValue: 1}
+	slow := &Key[int]{Key: "slow", OriginalValue: 2}
+	fast2 := &Key[int]{Key: "fast2", OriginalValue: 3}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		fast1.Key: {Id: 1, ModelVersion: currentModelVersion},
+		slow.Key:  {Id: 2, ModelVersion: currentModelVersion},
+		fast2.Key: {Id: 3, ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	found, missing, err := provider.MGet(ctx, []*Key[int]{fast1, slow, fast2}, currentModelVersion)
+
+	assert.Nil(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Len(t, found, 2) // chunks received before cancellation are returned
+	assert.Equal(t, 3, found[fast2].Id)
+	assert.Equal(t, []*Key[int]{slow}, missing)
+
+	v, err := provider.Get(context.TODO(), fast2, currentModelVersion)
+	assert.Nil(t, err)
+	assert.NotNil(t, v) // cancellation does not open circuit breaker
+}
+
+func TestRedisCacheRetry(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	hook := &failingHook{}
+	client.AddHook(hook)
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	values := map[string]*EntityToCache{
+		key.Key: {Id: 1, ModelVersion: currentModelVersion},
+	}
+
+	provider := NewRedisCache[EntityToCache, int](client, WithRetry(2, time.Millisecond))
+
+	hook.failures = 2
+	assert.Nil(t, provider.MSet(context.TODO(), values, time.Hour))
+
+	hook.failures = 2
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, 1, found[key].Id)
+
+	hook.failures = 3
+	found, _, err = provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, 0, hook.failures)
+
+	hook.failures = 1
+	err = NewRedisCache[EntityToCache, int](client).MSet(context.TODO(), values, time.Hour)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// inFlightHook tracks maximum amount of concurrently processed commands.
+type inFlightHook struct {
+	current atomic.Int32
+	max     atomic.Int32
+}
+
+func (h *inFlightHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *inFlightHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		current := h.current.Add(1)
+		defer h.current.Add(-1)
+
+		for {
+			prev := h.max.Load()
+			if current <= prev || h.max.CompareAndSwap(prev, current) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		return next(ctx, cmd)
+	}
+}
+
+func (h *inFlightHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisCacheMaxConcurrency(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	hook := &inFlightHook{}
+	client.AddHook(hook)
+
+	var keys []*Key[int]
+	for i := 0; i < 20; i++ {
+		keys = append(keys, &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i})
+	}
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(1), WithMaxConcurrency(2))
+
+	_, missing, err := provider.MGet(context.TODO(), keys, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, len(missing))
+	assert.LessOrEqual(t, hook.max.Load(), int32(2))
+}
+
+func TestRedisCacheMSetReportsFailedKeysInOrder(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	hook := &failingHook{}
+	client.AddHook(hook)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(1))
+
+	var keys []string
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 10; i++ {
+		keys = append(keys, fmt.Sprintf("entity:%v", i))
+		values[keys[i]] = &EntityToCache{Id: i, ModelVersion: currentModelVersion}
+	}
+
+	hook.failures = 1
+	err := provider.MSet(context.TODO(), values, time.Hour)
+
+	var failedErr *FailedKeysError
+	assert.True(t, errors.As(err, &failedErr))
+	assert.Equal(t, keys, failedErr.Keys)
+	assert.Empty(t, srv.Keys())
+}
+
+// commandsHook records names and arguments of every processed command.
+type commandsHook struct {
+	mut  sync.Mutex
+	args [][]interface{}
+}
+
+func (h *commandsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *commandsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(cmd)
+
+		return next(ctx, cmd)
+	}
+}
+
+func (h *commandsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(cmd)
+		}
+
+		return next(ctx, cmds)
+	}
+}
+
+func (h *commandsHook) record(cmd redis.Cmder) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	h.args = append(h.args, cmd.Args())
+}
+
+func TestRedisCacheMSetSetsTtlAtomically(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	hook := &commandsHook{}
+	client.AddHook(hook)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(1))
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"entity:1": {Id: 1, ModelVersion: currentModelVersion},
+		"entity:2": {Id: 2, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	assert.Equal(t, 2, len(hook.args))
+	for _, args := range hook.args {
+		assert.Equal(t, "set", args[0])
+		assert.Equal(t, "ex", args[3])
+	}
+
+	assert.Equal(t, time.Hour, srv.TTL("entity:1"))
+	assert.Equal(t, time.Hour, srv.TTL("entity:2"))
+}
+
+func BenchmarkRedisCacheChunkSize(b *testing.B) {
+	currentModelVersion := uint16(7)
+	srv := miniredis.NewMiniRedis()
+	if err := srv.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: srv.Addr(),
+	})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 1000; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, Value: "random_content", ModelVersion: currentModelVersion}
+	}
+
+	for _, size := range []int{10, 100, 500, 1000} {
+		provider := NewRedisCache[EntityToCache, int](client, WithChunkSize(size))
+
+		if err := provider.MSet(context.TODO(), values, time.Hour); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("chunk_%v", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := provider.MGet(context.TODO(), keys, currentModelVersion); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRedisCacheGetSliding(b *testing.B) {
+	currentModelVersion := uint16(7)
+	srv := miniredis.NewMiniRedis()
+	if err := srv.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: srv.Addr(),
+	})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+	plain := NewRedisCache[EntityToCache, int](client)
+	sliding := NewRedisCache[EntityToCache, int](client, WithSlidingExpiration(time.Hour))
+
+	if err := plain.MSet(context.TODO(), map[string]*EntityToCache{
+		key.Key: {Id: 1, Value: "random_content", ModelVersion: currentModelVersion},
+	}, time.Hour); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("get_expire", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := plain.Get(context.TODO(), key, currentModelVersion); err != nil {
+				b.Fatal(err)
+			}
+
+			if err := client.Expire(context.TODO(), key.Key, time.Hour).Err(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("getex", func(b *testing.B) { // PTTL and GETEX PX in single pipeline
+		for i := 0; i < b.N; i++ {
+			if _, err := sliding.Get(context.TODO(), key, currentModelVersion); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRedisCacheMGet(b *testing.B) {
+	currentModelVersion := uint16(7)
+	srv := miniredis.NewMiniRedis()
+	if err := srv.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: srv.Addr(),
+	})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 1000; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+		values[key.Key] = &EntityToCache{Id: i, Value: "random_content", ModelVersion: currentModelVersion}
+	}
+
+	provider := NewRedisCache[EntityToCache, int](client)
+	if err := provider.MSet(context.TODO(), values, time.Hour); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := provider.MGet(context.TODO(), keys, currentModelVersion); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRedisCacheDeleteByPrefixVersionedKeys(t *testing.T) {
+	srv, client := newTestRedis(t)
+	provider := NewRedisCache[EntityToCache, int](client, WithVersionedKeys(true)).(*RedisCache[EntityToCache, int])
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"tenant:1:a": {Id: 1, ModelVersion: 6},
+		"tenant:1:b": {Id: 2, ModelVersion: 7},
+		"tenant:2:a": {Id: 3, ModelVersion: 7},
+	}, time.Minute))
+
+	removed, err := provider.DeleteByPrefix(context.TODO(), "tenant:1:")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, []string{versionsKeyName, "v7:tenant:2:a"}, srv.Keys())
+
+	removed, err = provider.DeleteByPrefix(context.TODO(), "2:") // version glob matches v7:tenant:2:a as well
+	assert.Nil(t, err)
+	assert.Equal(t, 0, removed)
+	assert.Equal(t, []string{versionsKeyName, "v7:tenant:2:a"}, srv.Keys())
+}
+
+func TestRedisCacheTTLVersionedKeysFreshInstance(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	writer := NewRedisCache[EntityToCache, int](client, WithVersionedKeys(true))
+	assert.Nil(t, writer.MSet(context.TODO(), map[string]*EntityToCache{"a": {Id: 1, ModelVersion: 6}}, time.Minute))
+	assert.Nil(t, writer.MSet(context.TODO(), map[string]*EntityToCache{"a": {Id: 1, ModelVersion: 7}}, time.Hour))
+
+	fresh := NewRedisCache[EntityToCache, int](client, WithVersionedKeys(true)).(*RedisCache[EntityToCache, int])
+
+	ttl, err := fresh.TTL(context.TODO(), &Key[int]{Key: "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, ttl) // the longest one across versions
+
+	ttl, err = fresh.TTL(context.TODO(), &Key[int]{Key: "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, TTLNotFound, ttl)
+}
+
+func TestRedisCacheClient(t *testing.T) {
+	_, client := newTestRedis(t)
+	provider := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("app:")).(*RedisCache[EntityToCache, int])
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"entity:1": {Id: 1, ModelVersion: 7},
+	}, time.Minute))
+
+	assert.Same(t, client, provider.Client())
+
+	ttl, err := provider.Client().TTL(context.TODO(), "app:entity:1").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestRedisCacheSkipUnchangedWrites(t *testing.T) {
+	currentModelVersion := uint16(7)
+	srv, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client, WithSkipUnchangedWrites(true), WithChunkSize(2))
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+		"key2": {Id: 2, ModelVersion: currentModelVersion},
+		"key3": {Id: 3, ModelVersion: currentModelVersion},
+	}, time.Minute))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{"key4"}, currentModelVersion, time.Minute))
+
+	srv.FastForward(50 * time.Second)
+
+	hook := &commandsHook{}
+	client.AddHook(hook)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+		"key2": {Id: 20, ModelVersion: currentModelVersion},
+		"key3": {Id: 3, ModelVersion: currentModelVersion},
+		"key4": {Id: 4, ModelVersion: currentModelVersion},
+	}, time.Minute))
+
+	assert.Equal(t, time.Minute, srv.TTL("key1"))
+	assert.Equal(t, time.Minute, srv.TTL("key2"))
+	assert.Equal(t, time.Minute, srv.TTL("key3"))
+	assert.Equal(t, time.Minute, srv.TTL("key4"))
+
+	var written, expired []interface{}
+
+	for _, args := range hook.args {
+		switch args[0] {
+		case "set":
+			written = append(written, args[1])
+		case "pexpire":
+			expired = append(expired, args[1])
+		}
+	}
+
+	assert.Equal(t, []interface{}{"key2", "key4"}, written)
+	assert.Equal(t, []interface{}{"key1", "key3"}, expired)
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+	}, 0))
+	assert.Equal(t, time.Duration(0), srv.TTL("key1")) // unchanged value is persisted like written one
+}
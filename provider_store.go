@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// Store is generic key-value store, its method set matches gokv.Store, so any gokv backend
+// (BoltDB, etcd, DynamoDB and others) can be passed to NewStoreProvider as is.
+// Values are passed as encoded []byte, so store codec only wraps already encoded entities.
+type Store interface {
+	Set(k string, v any) error
+	Get(k string, v any) (found bool, err error)
+	Delete(k string) error
+}
+
+// StoreProvider adapts Store to Provider, entities are encoded and model version is checked like in RedisCache.
+type StoreProvider[T Entity, V any] struct {
+	store       Store
+	keyPrefix   string
+	codec       Codec
+	compression CompressionAlgorithm
+	logger      Logger
+	selfHeal    bool
+	keepStale   bool
+	now         func() time.Time
+}
+
+// NewStoreProvider creates provider backed by store. Stores have no expiration, so ttl of values is ignored
+// and they stay until overwritten, deleted or invalidated by model version bump. Tombstones carry their
+// expiry instead, expired ones are treated as missing and deleted on read.
+// WithKeyPrefix, WithCodec, WithCompression, WithLogger, WithSlog, WithSelfHealCorruptEntries
+// and WithKeepStaleVersions are applicable from provider options.
+func NewStoreProvider[T Entity, V any](
+	store Store,
+	opts ...ProviderOption,
+) *StoreProvider[T, V] {
+	o := newProviderOptions(opts...)
+
+	return &StoreProvider[T, V]{
+		store:       store,
+		keyPrefix:   o.keyPrefix,
+		codec:       o.codec,
+		compression: o.compression,
+		logger:      o.logger,
+		selfHeal:    o.selfHeal,
+		keepStale:   o.keepStale,
+		now:         time.Now,
+	}
+}
+
+// Get reports entry which can not be decoded as missing and logs it at Warn, as MGet does,
+// so only store failures are returned.
+func (s *StoreProvider[T, V]) Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	storeKey := s.storeKey(key.Key)
+
+	var bts []byte
+
+	found, err := s.store.Get(storeKey, &bts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	bts, expired := s.unwrapTombstone(bts)
+	if expired {
+		s.drop(storeKey)
+
+		return nil, nil
+	}
+
+	v, err := decodeEntity[T](s.codec, bts, requiredModelVersion)
+	if s.shouldDrop(err) {
+		s.drop(storeKey)
+	}
+
+	switch {
+	case errors.Is(err, ErrTombstone):
+		return nil, err
+	case errors.Is(err, errStaleVersion):
+		return nil, nil
+	case err != nil: // corrupt entry is a miss, only store failures are returned
+		s.logger.Warn(err, "can not decode cached value", keyCount(1))
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// MGet gets keys one by one, as Store has no batch reads. Keys which fail to be read are logged and missing.
+func (s *StoreProvider[T, V]) MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error) {
+	var missing []*Key[V]
+	results := make(map[*Key[V]]*T, len(keys))
+
+	for _, key := range keys {
+		v, err := s.Get(ctx, key, requiredModelVersion)
+
+		switch {
+		case errors.Is(err, ErrTombstone):
+			results[key] = nil
+		case err != nil:
+			s.logger.Warn(err, "can not get value from store", keyCount(1))
+			missing = append(missing, key)
+		case v == nil:
+			missing = append(missing, key)
+		default:
+			results[key] = v
+		}
+	}
+
+	return results, missing, nil
+}
+
+// MSet returns *FailedKeysError when part of values can not be encoded or written, ttl is ignored.
+func (s *StoreProvider[T, V]) MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error {
+	_ = ttl
+
+	var multiErr error
+	var failed []string
+
+	for _, k := range sortedKeys(values) {
+		err := ctx.Err()
+
+		var b []byte
+		if err == nil {
+			b, err = encodeEntity(s.codec, s.compression, values[k])
+		}
+
+		if err == nil {
+			err = s.store.Set(s.storeKey(k), b)
+		}
+
+		if err != nil {
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+			failed = append(failed, k)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &FailedKeysError{Keys: failed, Err: multiErr}
+	}
+
+	return nil
+}
+
+// SetTombstones writes tombstones expiring after ttl, see NewStoreProvider. Non-positive ttl never expires.
+func (s *StoreProvider[T, V]) SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error {
+	var multiErr error
+	tombstone := encodeTombstone(modelVersion)
+
+	if ttl > 0 {
+		tombstone = binary.BigEndian.AppendUint64(tombstone, uint64(s.now().Add(ttl).UnixNano()))
+	}
+
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(multiErr, errors.WithStack(err))
+		}
+
+		if err := s.store.Set(s.storeKey(k), tombstone); err != nil {
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+		}
+	}
+
+	return multiErr
+}
+
+func (s *StoreProvider[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	var multiErr error
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(multiErr, errors.WithStack(err))
+		}
+
+		if err := s.store.Delete(s.storeKey(key.Key)); err != nil {
+			multiErr = multierror.Append(multiErr, errors.WithStack(err))
+		}
+	}
+
+	return multiErr
+}
+
+// Clear is not supported, as Store can not enumerate keys. Bump model version to invalidate entries instead,
+// ErrClearNotSupported is returned.
+func (s *StoreProvider[T, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return errors.WithStack(ErrClearNotSupported)
+}
+
+// Exists reads raw value, as Store has no dedicated call, but skips decoding.
+func (s *StoreProvider[T, V]) Exists(ctx context.Context, key *Key[V]) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	var bts []byte
+
+	found, err := s.store.Get(s.storeKey(key.Key), &bts)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if _, expired := s.unwrapTombstone(bts); expired {
+		return false, nil
+	}
+
+	return found, nil
+}
+
+// unwrapTombstone strips expiry of tombstone written by SetTombstones and reports whether it has passed.
+// Other values, including compressed entries which share the marker but are longer, are returned as is.
+func (s *StoreProvider[T, V]) unwrapTombstone(bts []byte) ([]byte, bool) {
+	if len(bts) != 11 || bts[0] != tombstoneMarker {
+		return bts, false
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(bts[3:]))
+
+	return bts[:3], s.now().UnixNano() >= expiresAt
+}
+
+// shouldDrop reports whether entry with given decode error should be deleted,
+// see WithSelfHealCorruptEntries and WithKeepStaleVersions.
+func (s *StoreProvider[T, V]) shouldDrop(decodeErr error) bool {
+	switch {
+	case decodeErr == nil, errors.Is(decodeErr, ErrTombstone):
+		return false
+	case errors.Is(decodeErr, errStaleVersion):
+		return !s.keepStale
+	default:
+		return s.selfHeal
+	}
+}
+
+// drop is best-effort deletion of entry found invalid on read.
+func (s *StoreProvider[T, V]) drop(key string) {
+	if err := s.store.Delete(key); err != nil {
+		s.logger.Error(err, "can not delete invalid value", keyCount(1))
+	}
+}
+
+func (s *StoreProvider[T, V]) storeKey(key string) string {
+	return s.keyPrefix + key
+}
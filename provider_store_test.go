@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/philippgille/gokv/syncmap"
+	"github.com/stretchr/testify/assert"
+)
+
+// Store stays assignable from gokv backends, e.g. syncmap.
+var _ Store = syncmap.Store{}
+
+func TestStoreProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+	store := syncmap.NewStore(syncmap.DefaultOptions)
+	provider := NewStoreProvider[EntityToCache, int](store, WithKeyPrefix("app:"))
+
+	var keys []*Key[int]
+	values := map[string]*EntityToCache{}
+
+	for i := 0; i < 10; i++ {
+		key := &Key[int]{Key: fmt.Sprintf("entity:%v", i), OriginalValue: i}
+		keys = append(keys, key)
+
+		if i%2 == 0 {
+			values[key.Key] = &EntityToCache{Id: i, Value: "value", ModelVersion: currentModelVersion}
+		}
+	}
+
+	assert.Nil(t, provider.MSet(context.TODO(), values, time.Minute))
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{keys[1].Key}, currentModelVersion, time.Minute))
+	assert.Nil(t, store.Set("app:"+keys[3].Key, []byte("not msgpack")))
+
+	found, missing, err := provider.MGet(context.TODO(), keys, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Len(t, found, 6)
+	assert.Equal(t, "value", found[keys[2]].Value)
+	assert.Nil(t, found[keys[1]])
+	assert.Equal(t, []*Key[int]{keys[3], keys[5], keys[7], keys[9]}, missing)
+
+	exists, err := provider.Exists(context.TODO(), keys[3])
+	assert.Nil(t, err)
+	assert.False(t, exists) // corrupt entry is deleted
+
+	assert.Nil(t, store.Set("app:"+keys[3].Key, []byte("not msgpack")))
+	v, err := provider.Get(context.TODO(), keys[3], currentModelVersion)
+	assert.Nil(t, err) // corrupt entry is a miss
+	assert.Nil(t, v)
+
+	v, err = provider.Get(context.TODO(), keys[4], currentModelVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, v.Id)
+
+	_, err = provider.Get(context.TODO(), keys[1], currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	v, err = provider.Get(context.TODO(), keys[4], currentModelVersion+1)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	exists, err = provider.Exists(context.TODO(), keys[4])
+	assert.Nil(t, err)
+	assert.False(t, exists) // stale version is deleted
+
+	assert.Nil(t, provider.Delete(context.TODO(), keys[0]))
+	exists, err = provider.Exists(context.TODO(), keys[0])
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	assert.ErrorIs(t, provider.Clear(context.TODO()), ErrClearNotSupported)
+}
+
+func TestStoreProviderCanceledContext(t *testing.T) {
+	store := syncmap.NewStore(syncmap.DefaultOptions)
+	provider := NewStoreProvider[EntityToCache, int](store)
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{key.Key: {Id: 1}}, time.Minute))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := provider.Get(ctx, key, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = provider.Exists(ctx, key)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, provider.Delete(ctx, key), context.Canceled)
+
+	exists, err := provider.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
+
+func TestStoreProviderTombstoneExpiry(t *testing.T) {
+	currentModelVersion := uint16(7)
+	store := syncmap.NewStore(syncmap.DefaultOptions)
+	provider := NewStoreProvider[EntityToCache, int](store)
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	assert.Nil(t, provider.SetTombstones(context.TODO(), []string{key.Key}, currentModelVersion, time.Minute))
+
+	_, err := provider.Get(context.TODO(), key, currentModelVersion)
+	assert.ErrorIs(t, err, ErrTombstone)
+
+	found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Contains(t, found, key)
+	assert.Empty(t, missing)
+
+	now = now.Add(time.Minute)
+
+	exists, err := provider.Exists(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	found, missing, err = provider.MGet(context.TODO(), []*Key[int]{key}, currentModelVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, []*Key[int]{key}, missing)
+
+	var raw []byte
+	exists, err = store.Get(key.Key, &raw)
+	assert.Nil(t, err)
+	assert.False(t, exists) // expired tombstone is deleted
+}
+
+func TestStoreProviderCache(t *testing.T) {
+	key := &Key[int]{Key: "entity:1", OriginalValue: 1}
+
+	ch := NewCacheBuilder[EntityToCache, int](1, NewStoreProvider[EntityToCache, int](syncmap.NewStore(syncmap.DefaultOptions))).
+		WithSyncWriteback(true).
+		Build()
+
+	calls := 0
+	fn := func(ctx context.Context, key *Key[int]) (*EntityToCache, error) {
+		calls++
+		return &EntityToCache{Id: key.OriginalValue, ModelVersion: 1}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := ch.Get(context.TODO(), key, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v.Id)
+	}
+
+	assert.Equal(t, 1, calls)
+}
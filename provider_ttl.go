@@ -0,0 +1,41 @@
+package cache
+
+import "time"
+
+type ttlProvider[T, V any] struct {
+	Provider[T, V]
+	ttl time.Duration
+}
+
+// WithProviderTtl overrides builder ttl of values written by Cache to provider, builder ttl is used for
+// providers without override. Ttl of entities implementing TTLProvider and WithCallTtl take precedence,
+// so precedence is entity ttl, call or source ttl, provider ttl and builder ttl.
+// Ttl of negatively cached keys is not affected, ttl passed to MSet of returned provider is used as is.
+func WithProviderTtl[T, V any](provider Provider[T, V], ttl time.Duration) Provider[T, V] {
+	return &ttlProvider[T, V]{
+		Provider: provider,
+		ttl:      ttl,
+	}
+}
+
+func (p *ttlProvider[T, V]) unwrap() any {
+	return p.Provider
+}
+
+func (p *ttlProvider[T, V]) providerTtl() time.Duration {
+	return p.ttl
+}
+
+// providerTtl returns ttl of WithProviderTtl wrapping provider or any provider wrapped by it, ttl otherwise.
+func providerTtl(provider any, ttl time.Duration) time.Duration {
+	for {
+		switch p := provider.(type) {
+		case interface{ providerTtl() time.Duration }:
+			return p.providerTtl()
+		case interface{ unwrap() any }:
+			provider = p.unwrap()
+		default:
+			return ttl
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+)
+
+// RawEntity boxes value which can not implement Entity, e.g. string or int, together with model version.
+type RawEntity[T any] struct {
+	Value        T
+	ModelVersion uint16
+}
+
+func (e RawEntity[T]) GetCacheModelVersion() uint16 {
+	return e.ModelVersion
+}
+
+// RawCache is facade of Cache storing values which are not Entity, model version is taken from cache
+// instead of value. Providers and builder are created for RawEntity[T], e.g.
+//
+//	raw := NewRawCache(NewCacheBuilder[RawEntity[string], int](1, NewRedisCache[RawEntity[string], int](client)).Build())
+type RawCache[T, V any] struct {
+	cache *Cache[RawEntity[T], V]
+}
+
+// NewRawCache wraps cache of boxed values.
+func NewRawCache[T, V any](c *Cache[RawEntity[T], V]) *RawCache[T, V] {
+	return &RawCache[T, V]{
+		cache: c,
+	}
+}
+
+// Cache returns wrapped cache for operations RawCache does not expose.
+func (r *RawCache[T, V]) Cache() *Cache[RawEntity[T], V] {
+	return r.cache
+}
+
+// Get is Cache.Get for raw values, nil is returned when key has no value.
+// Nil fn falls back to loader attached by WithLoader typed on raw value, then to loaders of wrapped cache.
+func (r *RawCache[T, V]) Get(ctx context.Context, key *Key[V], fn GetSingleFromSourceFn[T, V], opts ...CallOption) (*T, error) {
+	var boxedFn GetSingleFromSourceFn[RawEntity[T], V]
+
+	if fn == nil {
+		fn = rawLoaders[T, V](ctx).single
+	}
+
+	if fn != nil {
+		boxedFn = func(ctx context.Context, key *Key[V]) (*RawEntity[T], error) {
+			v, err := fn(ctx, key)
+
+			return r.box(v), err
+		}
+	}
+
+	v, err := r.cache.Get(ctx, key, boxedFn, opts...)
+	if v == nil {
+		return nil, err
+	}
+
+	return &v.Value, err
+}
+
+// MGet is Cache.MGet for raw values, nil fn falls back to loaders as in Get.
+func (r *RawCache[T, V]) MGet(
+	ctx context.Context,
+	keys []*Key[V],
+	fn GetFromSourceFn[T, V],
+	opts ...CallOption,
+) (map[*Key[V]]*T, error) {
+	var boxedFn GetFromSourceFn[RawEntity[T], V]
+
+	if fn == nil {
+		fn = rawLoaders[T, V](ctx).multi
+	}
+
+	if fn != nil {
+		boxedFn = func(ctx context.Context, keys []*Key[V]) (map[*Key[V]]*RawEntity[T], error) {
+			values, err := fn(ctx, keys)
+
+			boxed := make(map[*Key[V]]*RawEntity[T], len(values))
+			for k, v := range values {
+				boxed[k] = r.box(v)
+			}
+
+			return boxed, err
+		}
+	}
+
+	boxed, err := r.cache.MGet(ctx, keys, boxedFn, opts...)
+	if boxed == nil {
+		return nil, err
+	}
+
+	values := make(map[*Key[V]]*T, len(boxed))
+	for k, v := range boxed {
+		if v != nil {
+			values[k] = &v.Value
+		}
+	}
+
+	return values, err
+}
+
+// Set is Cache.Set for raw value.
+func (r *RawCache[T, V]) Set(ctx context.Context, key *Key[V], value T) error {
+	return r.cache.Set(ctx, key, r.box(&value))
+}
+
+// MSet is Cache.MSet for raw values.
+func (r *RawCache[T, V]) MSet(ctx context.Context, records map[string]T) error {
+	boxed := make(map[string]*RawEntity[T], len(records))
+
+	for k, v := range records {
+		boxed[k] = r.box(&v)
+	}
+
+	return r.cache.MSet(ctx, boxed)
+}
+
+func (r *RawCache[T, V]) Delete(ctx context.Context, keys ...*Key[V]) error {
+	return r.cache.Delete(ctx, keys...)
+}
+
+func (r *RawCache[T, V]) box(v *T) *RawEntity[T] {
+	if v == nil {
+		return nil
+	}
+
+	return &RawEntity[T]{
+		Value:        *v,
+		ModelVersion: r.cache.ModelVersion(),
+	}
+}
+
+// rawLoaders returns loaders attached to ctx by WithLoader for raw value type, as wrapped cache only looks up
+// loaders of RawEntity.
+func rawLoaders[T, V any](ctx context.Context) contextLoaders[T, V] {
+	l, _ := ctx.Value(loaderKey[T, V]{}).(contextLoaders[T, V])
+
+	return l
+}
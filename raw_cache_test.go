@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawCache(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	raw := NewRawCache(NewCacheBuilder[RawEntity[string], int](7, NewRedisCache[RawEntity[string], int](client)).
+		WithSyncWriteback(true).
+		Build())
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+	key3 := &Key[int]{Key: "key3", OriginalValue: 3}
+	sourceCalls := 0
+
+	fn := func(ctx context.Context, key *Key[int]) (*string, error) {
+		sourceCalls++
+		v := "loaded"
+
+		return &v, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := raw.Get(context.TODO(), key1, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, "loaded", *v)
+	}
+	assert.Equal(t, 1, sourceCalls)
+
+	assert.Nil(t, raw.Set(context.TODO(), key2, "set"))
+
+	values, err := raw.MGet(context.TODO(), []*Key[int]{key1, key2, key3},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*string, error) {
+			assert.Equal(t, []*Key[int]{key3}, keys)
+
+			return map[*Key[int]]*string{}, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, "loaded", *values[key1])
+	assert.Equal(t, "set", *values[key2])
+
+	raw.Cache().SetModelVersion(8)
+
+	v, err := raw.Get(context.TODO(), key1, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, "loaded", *v)
+	assert.Equal(t, 2, sourceCalls)
+}
+
+func TestRawCacheMGetNilFromSource(t *testing.T) {
+	raw := NewRawCache(NewCacheBuilder[RawEntity[string], int](7, NewMapCache[RawEntity[string], int]()).Build())
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+
+	values, err := raw.MGet(context.TODO(), []*Key[int]{key1},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*string, error) {
+			return map[*Key[int]]*string{key1: nil}, nil
+		})
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+func TestRawCacheContextLoader(t *testing.T) {
+	raw := NewRawCache(NewCacheBuilder[RawEntity[string], int](7, NewMapCache[RawEntity[string], int]()).Build())
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	ctx := WithLoader[string, int](context.TODO(),
+		func(ctx context.Context, key *Key[int]) (*string, error) {
+			v := "single"
+
+			return &v, nil
+		},
+		func(ctx context.Context, keys []*Key[int]) (map[*Key[int]]*string, error) {
+			v := "multi"
+
+			return map[*Key[int]]*string{keys[0]: &v}, nil
+		})
+
+	v, err := raw.Get(ctx, key1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "single", *v)
+
+	values, err := raw.MGet(ctx, []*Key[int]{key2}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "multi", *values[key2])
+
+	_, err = raw.Get(context.TODO(), &Key[int]{Key: "key3"}, nil)
+	assert.ErrorIs(t, err, ErrNoSourceFn)
+}
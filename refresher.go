@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// refreshers holds cancel functions of background refreshes per key, see Cache.RegisterRefresh.
+type refreshers struct {
+	mut     sync.Mutex
+	cancels map[string]context.CancelFunc
+	closed  bool
+}
+
+// add returns context of new refresh of key, false is returned when key is already refreshed.
+// ErrClosed is returned after close, so refresh registered concurrently with Cache.Close is never leaked.
+func (r *refreshers) add(key string) (context.Context, bool, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.closed {
+		return nil, false, errors.WithStack(ErrClosed)
+	}
+
+	if _, ok := r.cancels[key]; ok {
+		return nil, false, nil
+	}
+
+	if r.cancels == nil {
+		r.cancels = map[string]context.CancelFunc{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancels[key] = cancel
+
+	return ctx, true, nil
+}
+
+func (r *refreshers) remove(key string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if cancel, ok := r.cancels[key]; ok {
+		cancel()
+		delete(r.cancels, key)
+	}
+}
+
+// close stops all refreshes and rejects new ones.
+func (r *refreshers) close() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.closed = true
+
+	for key, cancel := range r.cancels {
+		cancel()
+		delete(r.cancels, key)
+	}
+}
+
+// RegisterRefresh refreshes key from source right away and then every interval in background, so it never
+// expires and is never loaded by Get. Interval is jittered by up to 10% to spread load on source.
+// Registering already refreshed key does nothing, refresh is stopped by UnregisterRefresh or Close.
+// nil fn falls back to Builder.WithDefaultLoader, failed refreshes are logged and retried on next interval.
+func (c *Cache[T, V]) RegisterRefresh(key *Key[V], fn GetSingleFromSourceFn[T, V], interval time.Duration) error {
+	if c.closed.Load() {
+		return errors.WithStack(ErrClosed)
+	}
+
+	if interval <= 0 {
+		return errors.Errorf("refresh interval must be positive, got %v", interval)
+	}
+
+	if fn == nil {
+		fn = c.builder.singleLoader
+	}
+
+	if fn == nil {
+		return errors.WithStack(ErrNoSourceFn)
+	}
+
+	ctx, ok, err := c.refreshers.add(key.Key)
+	if err != nil || !ok {
+		return err
+	}
+
+	c.async(func() {
+		for {
+			if _, err := c.Refresh(ctx, key, fn); err != nil && ctx.Err() == nil {
+				c.builder.logger.Error(err, "can not refresh registered key", keyCount(1))
+			}
+
+			timer := time.NewTimer(jitter(interval))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	})
+
+	return nil
+}
+
+// UnregisterRefresh stops background refresh of key started by RegisterRefresh, value stays cached until ttl.
+func (c *Cache[T, V]) UnregisterRefresh(key *Key[V]) {
+	c.refreshers.remove(key.Key)
+}
+
+// jitter returns interval randomly shifted by up to 10% in either direction.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+
+	return interval + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
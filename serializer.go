@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// tombstoneMarker is never used byte in msgpack spec and invalid in utf-8,
+// so it can not be confused with entity encoded by bundled codecs.
+const tombstoneMarker = byte(0xc1)
+
+// errStaleVersion is returned by decodeEntity for entries written with other model version.
+var errStaleVersion = errors.New("cached value has stale model version")
+
+// Unversioned model version disables version check, entries are valid regardless of stored model version.
+// Cache using it must not share store with cache using versions, as it serves entries of any version.
+const Unversioned uint16 = 0
+
+// versionMatches reports whether entry of stored model version is valid for required one.
+func versionMatches(stored, required uint16) bool {
+	return required == Unversioned || stored == required
+}
+
+func encodeEntity[T any](codec Codec, compression CompressionAlgorithm, item *T) ([]byte, error) {
+	b, err := codec.Marshal(item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return compress(compression, b)
+}
+
+func encodeTombstone(modelVersion uint16) []byte {
+	tombstone := make([]byte, 3)
+	tombstone[0] = tombstoneMarker
+	binary.BigEndian.PutUint16(tombstone[1:], modelVersion)
+
+	return tombstone
+}
+
+// isTombstone reports whether bts is tombstone written by encodeTombstone, compressed entries share its marker.
+func isTombstone(bts []byte) bool {
+	return len(bts) == 3 && bts[0] == tombstoneMarker
+}
+
+// decodeEntity returns errStaleVersion for stale model version and ErrTombstone for negatively cached key.
+func decodeEntity[T Entity](codec Codec, bts []byte, requiredModelVersion uint16) (*T, error) {
+	item := new(T)
+	if err := decodeEntityInto(codec, bts, requiredModelVersion, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// decodeEntityInto is decodeEntity into preallocated item, so batch decoding allocates once per batch.
+func decodeEntityInto[T Entity](codec Codec, bts []byte, requiredModelVersion uint16, item *T) error {
+	if isTombstone(bts) {
+		if versionMatches(binary.BigEndian.Uint16(bts[1:]), requiredModelVersion) {
+			return ErrTombstone
+		}
+
+		return errStaleVersion
+	}
+
+	bts, err := decompress(bts)
+	if err != nil {
+		return err
+	}
+
+	if err = codec.Unmarshal(bts, item); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !versionMatches((*item).GetCacheModelVersion(), requiredModelVersion) {
+		return errStaleVersion
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+type snapshot[T any] struct {
+	ModelVersion uint16        `json:"model_version"`
+	Entries      map[string]*T `json:"entries"`
+}
+
+// Export dumps entries of current model version as JSON, intended for debugging and seeding fresh cache with Import.
+// Only providers implementing Scanner are enumerated, e.g. LRUCache, ShardedLRUCache, RedisCache and RedisHashCache,
+// value of faster provider wins. Tombstones are not exported. Values are read with provider MGet, so export extends
+// ttl of every exported entry of provider with WithSlidingExpiration.
+func (c *Cache[T, V]) Export(ctx context.Context) ([]byte, error) {
+	if c.closed.Load() {
+		return nil, errors.WithStack(ErrClosed)
+	}
+
+	modelVersion := c.ModelVersion()
+	snap := snapshot[T]{
+		ModelVersion: modelVersion,
+		Entries:      map[string]*T{},
+	}
+
+	for _, provider := range c.builder.providers {
+		scanner, ok := UnwrapProvider(provider).(Scanner)
+		if !ok {
+			continue
+		}
+
+		var keys []*Key[V]
+
+		if err := scanner.Scan(ctx, "", func(key string) bool {
+			if _, ok := snap.Entries[key]; !ok {
+				keys = append(keys, &Key[V]{Key: key})
+			}
+
+			return true
+		}); err != nil {
+			return nil, errors.Wrapf(err, "can not scan provider %v", providerName(provider))
+		}
+
+		if len(keys) == 0 {
+			continue
+		}
+
+		found, _, err := provider.MGet(ctx, keys, modelVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can not get from provider %v", providerName(provider))
+		}
+
+		for k, v := range found {
+			if v != nil {
+				snap.Entries[k.Key] = v
+			}
+		}
+	}
+
+	bts, err := json.Marshal(snap)
+
+	return bts, errors.WithStack(err)
+}
+
+// Import writes entries dumped by Export to every provider with MSet.
+// Snapshot of other model version is rejected with ErrModelVersionMismatch.
+func (c *Cache[T, V]) Import(ctx context.Context, data []byte) error {
+	var snap snapshot[T]
+
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return errors.Wrap(err, "can not decode snapshot")
+	}
+
+	if snap.ModelVersion != c.ModelVersion() {
+		return errors.Wrapf(ErrModelVersionMismatch, "snapshot has model version %v", snap.ModelVersion)
+	}
+
+	if len(snap.Entries) == 0 {
+		return nil
+	}
+
+	return c.setProviders(ctx, snap.Entries, true)
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	l1 := NewLRUCache[EntityToCache, int](10, time.Hour)
+	l2 := NewRedisCache[EntityToCache, int](client, WithKeyPrefix("snapshot:"))
+
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, l1, l2).Build()
+
+	key1 := &Key[int]{Key: "key1", OriginalValue: 1}
+	key2 := &Key[int]{Key: "key2", OriginalValue: 2}
+
+	assert.Nil(t, ch.MSet(context.TODO(), map[string]*EntityToCache{
+		key1.Key: {Id: 1, Value: "first", ModelVersion: currentModelVersion},
+	}))
+	assert.Nil(t, l2.MSet(context.TODO(), map[string]*EntityToCache{
+		key2.Key: {Id: 2, Value: "second", ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	data, err := ch.Export(context.TODO())
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Clear(context.TODO()))
+	assert.Equal(t, 0, l1.Len())
+
+	assert.Nil(t, ch.Import(context.TODO(), data))
+
+	for _, provider := range []Provider[EntityToCache, int]{l1, l2} {
+		found, missing, err := provider.MGet(context.TODO(), []*Key[int]{key1, key2}, currentModelVersion)
+		assert.Nil(t, err)
+		assert.Empty(t, missing)
+		assert.Equal(t, "first", found[key1].Value)
+		assert.Equal(t, "second", found[key2].Value)
+	}
+
+	ch.SetModelVersion(currentModelVersion + 1)
+	assert.ErrorIs(t, ch.Import(context.TODO(), data), ErrModelVersionMismatch)
+}
+
+func TestExportWrappedProvider(t *testing.T) {
+	currentModelVersion := uint16(7)
+	_, client := newTestRedis(t)
+
+	provider := NewRedisCache[EntityToCache, int](client)
+	ch := NewCacheBuilder[EntityToCache, int](currentModelVersion, WithProviderTtl(provider, time.Minute)).Build()
+
+	assert.Nil(t, provider.MSet(context.TODO(), map[string]*EntityToCache{
+		"key1": {Id: 1, ModelVersion: currentModelVersion},
+	}, time.Hour))
+
+	data, err := ch.Export(context.TODO())
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"key1"`)
+}
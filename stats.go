@@ -0,0 +1,27 @@
+package cache
+
+import "sync/atomic"
+
+type CacheStats struct {
+	Hits         uint64
+	Misses       uint64
+	SourceCalls  uint64
+	SourceErrors uint64
+}
+
+type cacheStats struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	sourceCalls  atomic.Uint64
+	sourceErrors atomic.Uint64
+}
+
+// Stats returns snapshot of counters, for MGet hits and misses are counted per key.
+func (c *Cache[T, V]) Stats() CacheStats {
+	return CacheStats{
+		Hits:         c.stats.hits.Load(),
+		Misses:       c.stats.misses.Load(),
+		SourceCalls:  c.stats.sourceCalls.Load(),
+		SourceErrors: c.stats.sourceErrors.Load(),
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// startSpan returns noop span when tracer is not configured.
+func (c *Cache[T, V]) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if c.builder.tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	return c.builder.tracer.Start(ctx, name)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func providerName(provider any) string {
+	name := fmt.Sprintf("%T", UnwrapProvider(provider))
+
+	if i := strings.Index(name, "["); i > 0 {
+		name = name[:i]
+	}
+
+	return strings.TrimPrefix(name, "*")
+}
+
+// UnwrapProvider returns provider wrapped by WithProviderTtl and WithBackfillOnly, as wrappers do not expose
+// optional capabilities of wrapped provider. Use it before type assertion to Incrementer, Scanner and others.
+func UnwrapProvider(provider any) any {
+	for {
+		w, ok := provider.(interface{ unwrap() any })
+		if !ok {
+			return provider
+		}
+
+		provider = w.unwrap()
+	}
+}
@@ -2,23 +2,116 @@ package cache
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type Provider[T, V any] interface {
 	Get(ctx context.Context, key *Key[V], requiredModelVersion uint16) (*T, error)
 	MGet(ctx context.Context, keys []*Key[V], requiredModelVersion uint16) (map[*Key[V]]*T, []*Key[V], error)
+	// MSet returns *FailedKeysError when only part of values was written.
+	// Concurrent writes of the same key are not merged, the last one written wins.
 	MSet(ctx context.Context, values map[string]*T, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...*Key[V]) error
+	Clear(ctx context.Context) error
+	// Exists reports whether key is stored without decoding value, so it returns true for entries
+	// of stale model version and negatively cached keys.
+	Exists(ctx context.Context, key *Key[V]) (bool, error)
+	// SetTombstones negatively caches keys for modelVersion, Get returns ErrTombstone and MGet
+	// reports such keys as found with nil value.
+	SetTombstones(ctx context.Context, keys []string, modelVersion uint16, ttl time.Duration) error
+}
+
+// Incrementer is optional provider capability of atomic counters, check it with type assertion of UnwrapProvider.
+// Counters are stored as plain integers, not as encoded entities, so they can not be read with Get or MGet.
+type Incrementer[V any] interface {
+	Increment(ctx context.Context, key *Key[V], delta int64) (int64, error)
+}
+
+// Updater is optional provider capability of atomic read-modify-write, check it with type assertion of UnwrapProvider.
+// fn receives nil for missing key, nil returned by fn deletes key. Error returned by fn aborts update and is returned as is.
+type Updater[T, V any] interface {
+	Update(ctx context.Context, key *Key[V], modelVersion uint16, ttl time.Duration, fn func(current *T) (*T, error)) (*T, error)
+}
+
+// PrefixDeleter is optional provider capability of removing all keys starting with prefix, see Cache.DeleteByPrefix.
+type PrefixDeleter interface {
+	DeleteByPrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// TTLReader is optional provider capability of reporting remaining ttl of key, see Cache.TTL.
+// TTLNoExpiry is returned for key without expiration and TTLNotFound for missing key, as by redis PTTL.
+type TTLReader[V any] interface {
+	TTL(ctx context.Context, key *Key[V]) (time.Duration, error)
+}
+
+// Remaining ttl of key without expiration and of missing key, see TTLReader.
+const (
+	TTLNoExpiry time.Duration = -1
+	TTLNotFound time.Duration = -2
+)
+
+// Pinger is optional provider capability of checking backend reachability, see Cache.Ping.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Scanner is optional provider capability of listing cached keys, check it with type assertion of UnwrapProvider.
+// Scan calls fn for every key starting with prefix until fn returns false, it is intended for debugging.
+type Scanner interface {
+	Scan(ctx context.Context, prefix string, fn func(key string) bool) error
 }
 
 type Builder[T, V any] struct {
-	providers    []Provider[T, V]
-	ttl          time.Duration
-	modelVersion uint16
+	providers     []Provider[T, V]
+	ttl           time.Duration
+	modelVersion  uint16
+	singleflight  bool
+	negativeTtl   time.Duration
+	observer      Observer
+	tracer        trace.Tracer
+	syncWriteback bool
+
+	writebackErrorHandler  func(err error)
+	logger                 Logger
+	singleLoader           GetSingleFromSourceFn[T, V]
+	multiLoader            GetFromSourceFn[T, V]
+	sourceTimeout          time.Duration
+	strictVersion          bool
+	partialResults         bool
+	readRepair             bool
+	parallelReads          bool
+	keyFunc                KeyFunc[V]
+	writeBehindInterval    time.Duration
+	writeBehindBatch       int
+	acceptedVersions       []uint16
+	upgrader               VersionUpgrader[T]
+	failOnProviderError    bool
+	promotionThreshold     int
+	batchCoalescing        bool
+	writeThrough           SourceWriteFn[T]
+	writeThroughCacheFirst bool
+	invalidTtl             time.Duration // negative ttl passed to WithTtl, reported by Build
 }
 
 type Cache[T any, V any] struct {
-	builder *Builder[T, V]
+	builder      *Builder[T, V]
+	group        singleflight.Group
+	stats        cacheStats
+	modelVersion atomic.Uint32
+	ttl          atomic.Int64
+	negativeTtl  atomic.Int64
+	closed       atomic.Bool
+	asyncMut     sync.Mutex
+	asyncWg      sync.WaitGroup
+	writeBehind  *writeBehind[T, V]
+	promotion    *promotionSketch
+	refreshers   refreshers
+	batches      batchCoalescer[T, V]
 }
 
 type Key[V any] struct {
@@ -26,14 +119,51 @@ type Key[V any] struct {
 	OriginalValue V
 }
 
+// KeyFunc derives cache key from original value, see Builder.WithKeyFunc.
+type KeyFunc[V any] func(original V) string
+
+// VersionUpgrader converts item of older model version to current one, see Builder.WithVersionUpgrader.
+type VersionUpgrader[T any] func(item *T, version uint16) (*T, error)
+
 type GetFromSourceFn[T, V any] func(ctx context.Context, key []*Key[V]) (map[*Key[V]]*T, error)
 type GetSingleFromSourceFn[T, V any] func(ctx context.Context, key *Key[V]) (*T, error)
 
+// GetSingleFromSourceFnWithTTL is GetSingleFromSourceFn which also returns ttl of loaded value, see Cache.GetWithTTLFromSource.
+type GetSingleFromSourceFnWithTTL[T, V any] func(ctx context.Context, key *Key[V]) (*T, time.Duration, error)
+
+// SourceWriteFn persists records keyed by cache key to source, see Builder.WithWriteThrough.
+type SourceWriteFn[T any] func(ctx context.Context, records map[string]*T) error
+
+// GetFromSourceFnWithErrors is GetFromSourceFn which reports failure of individual keys in key errors map.
+type GetFromSourceFnWithErrors[T, V any] func(ctx context.Context, key []*Key[V]) (map[*Key[V]]*T, map[*Key[V]]error, error)
+
+// GetResult describes where value returned by Cache.GetWithMeta came from.
+type GetResult struct {
+	Hit           bool // served by provider, including negatively cached key
+	ProviderIndex int  // index of provider which served value, -1 when served by source
+	FromSource    bool
+}
+
+// MGetMeta describes where values returned by Cache.MGetWithMeta came from, duplicate keys are counted once.
+type MGetMeta[V any] struct {
+	ProviderHits []int     // keys served per provider index, including negatively cached keys
+	FromSource   int       // keys loaded by source
+	NotFound     []*Key[V] // keys without value, see Cache.MGetWithMissing
+}
+
 type Entity interface {
 	GetCacheModelVersion() uint16
 }
 
+// TTLProvider is optional Entity capability of choosing its own ttl, which replaces ttl configured by builder,
+// WithProviderTtl or WithCallTtl when entity is written by Cache. Non positive ttl falls back to configured one.
+// It may be implemented on T or *T.
+type TTLProvider interface {
+	GetCacheTTL() time.Duration
+}
+
 type missingData[T, V any] struct {
+	index       int
 	provider    Provider[T, V]
 	missingKeys []*Key[V]
 }
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// writeBehindTarget identifies batch of values written to one provider with the same ttl.
+type writeBehindTarget struct {
+	index int
+	ttl   time.Duration
+}
+
+// writeBehind accumulates MGet writebacks and flushes them in batches, see Builder.WithWriteBehind.
+type writeBehind[T, V any] struct {
+	mut      sync.Mutex
+	pending  map[writeBehindTarget]map[string]*T
+	size     int
+	maxBatch int
+	flush    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  bool
+	done     chan struct{}
+}
+
+func newWriteBehind[T, V any](maxBatch int) *writeBehind[T, V] {
+	return &writeBehind[T, V]{
+		pending:  map[writeBehindTarget]map[string]*T{},
+		maxBatch: maxBatch,
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// add buffers values for providers which missed them and requests flush once batch is full.
+// It returns false without buffering once close was called, as final flush may already be done.
+func (w *writeBehind[T, V]) add(missingIn []missingData[T, V], values map[*Key[V]]*T, ttl time.Duration) bool {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if w.stopped {
+		return false
+	}
+
+	for _, m := range missingIn {
+		target := writeBehindTarget{index: m.index, ttl: ttl}
+
+		for _, k := range m.missingKeys {
+			v, ok := values[k]
+			if !ok {
+				continue
+			}
+
+			batch, ok := w.pending[target]
+			if !ok {
+				batch = map[string]*T{}
+				w.pending[target] = batch
+			}
+
+			if _, ok = batch[k.Key]; !ok {
+				w.size++
+			}
+
+			batch[k.Key] = v
+		}
+	}
+
+	if w.maxBatch > 0 && w.size >= w.maxBatch {
+		select {
+		case w.flush <- struct{}{}:
+		default: // flush already requested
+		}
+	}
+
+	return true
+}
+
+// discard drops buffered values of keys, so flush does not overwrite values written or deleted explicitly.
+func (w *writeBehind[T, V]) discard(keys ...string) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	for _, batch := range w.pending {
+		for _, k := range keys {
+			if _, ok := batch[k]; ok {
+				delete(batch, k)
+				w.size--
+			}
+		}
+	}
+}
+
+// take returns buffered values and resets buffer.
+func (w *writeBehind[T, V]) take() map[writeBehindTarget]map[string]*T {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	pending := w.pending
+	w.pending = map[writeBehindTarget]map[string]*T{}
+	w.size = 0
+
+	return pending
+}
+
+// run flushes buffer every interval or when batch is full until close, remaining values are flushed on close.
+func (w *writeBehind[T, V]) run(interval time.Duration, write func(pending map[writeBehindTarget]map[string]*T)) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flush:
+		case <-w.stop:
+			write(w.take())
+			return
+		}
+
+		write(w.take())
+	}
+}
+
+// close stops flushing loop and waits for final flush until ctx is done.
+func (w *writeBehind[T, V]) close(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		w.mut.Lock()
+		w.stopped = true
+		w.mut.Unlock()
+
+		close(w.stop)
+	})
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,35 @@
+package zerolog
+
+import (
+	"log/slog"
+
+	zl "github.com/rs/zerolog"
+	cache "github.com/skynet2/datasource-cache"
+)
+
+type Logger struct {
+	logger zl.Logger
+}
+
+// NewLogger adapts zerolog logger to cache.Logger.
+func NewLogger(logger zl.Logger) *Logger {
+	return &Logger{logger: logger}
+}
+
+var _ cache.Logger = (*Logger)(nil)
+
+func (l *Logger) Warn(err error, msg string, attrs ...slog.Attr) {
+	withAttrs(l.logger.Warn().Err(err), attrs).Msg(msg)
+}
+
+func (l *Logger) Error(err error, msg string, attrs ...slog.Attr) {
+	withAttrs(l.logger.Error().Err(err), attrs).Msg(msg)
+}
+
+func withAttrs(event *zl.Event, attrs []slog.Attr) *zl.Event {
+	for _, attr := range attrs {
+		event = event.Interface(attr.Key, attr.Value.Any())
+	}
+
+	return event
+}
@@ -0,0 +1,21 @@
+package zerolog
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/pkg/errors"
+	zl "github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := NewLogger(zl.New(&buf))
+	l.Warn(errors.New("bad bytes"), "can not decode cached value", slog.Int("key_count", 1))
+
+	assert.JSONEq(t, `{"level":"warn","error":"bad bytes","key_count":1,"message":"can not decode cached value"}`,
+		buf.String())
+}